@@ -0,0 +1,55 @@
+package wendy
+
+// AuditEvent describes a fairness violation: a committed block placed First
+// before Second even though more validators reported Second before First.
+type AuditEvent struct {
+	First  Hash
+	Second Hash
+
+	// FirstVotes is the number of validators that reported First before
+	// Second, see BeforeCount.
+	FirstVotes int
+	// SecondVotes is the number of validators that reported Second before
+	// First, see BeforeCount.
+	SecondVotes int
+}
+
+// SetAuditor sets a function that is invoked by CommitBlock for every pair of
+// txs of the committed block whose order contradicts the order reported by
+// the majority of the validators (see BeforeCount).
+// Blocks built by NewBlock are sorted by hash rather than by the votes, so
+// the auditor reports their txs that the majority reported in the opposite
+// order, even when they don't block each other. Blocks are expected to be
+// ordered as the votes report them, e.g by BlockingSet.Order.
+// The auditor is called while holding Wendy's locks, therefore it must not
+// call Wendy methods, otherwise it will deadlock.
+// Passing nil disables the auditing.
+func (w *Wendy) SetAuditor(fn func(AuditEvent)) {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.auditor = fn
+}
+
+// audit compares the order of the block's txs against the votes and emits an
+// AuditEvent for every contradiction.
+// It must be called before the block's votes are pruned.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) audit(block Block) {
+	if w.auditor == nil {
+		return
+	}
+
+	for i, tx1 := range block.Txs {
+		for _, tx2 := range block.Txs[i+1:] {
+			// txs with different labels are never ordered, both counts are 0.
+			first, second := w.beforeCount(tx1, tx2), w.beforeCount(tx2, tx1)
+			if second > first {
+				w.auditor(AuditEvent{
+					First: tx1.Hash(), Second: tx2.Hash(),
+					FirstVotes: first, SecondVotes: second,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditor(t *testing.T) {
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: {testTx0, testTx1, testTx2},
+			&pub1: {testTx0, testTx1, testTx2},
+			&pub2: {testTx1, testTx0, testTx2},
+		},
+	)
+
+	var events []AuditEvent
+	w.SetAuditor(func(e AuditEvent) {
+		events = append(events, e)
+	})
+
+	// testTx1 is placed before testTx0 while 2 out of 3 peers reported
+	// testTx0 first.
+	w.CommitBlock(Block{Txs: []Tx{testTx1, testTx0}})
+	assert.Equal(t, []AuditEvent{
+		{First: testTx1.Hash(), Second: testTx0.Hash(), FirstVotes: 1, SecondVotes: 2},
+	}, events)
+
+	events = nil
+	w.CommitBlock(Block{Txs: []Tx{testTx2}})
+	assert.Empty(t, events)
+}
@@ -1,7 +1,9 @@
 package wendy
 
 import (
+	"crypto/ed25519"
 	"fmt"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -49,3 +51,135 @@ func benchmarkIsBlockedBy(b *testing.B, n int) {
 		w.IsBlockedBy(txs[0], txs[1])
 	}
 }
+
+func BenchmarkBlockingSet100(b *testing.B)        { benchmarkBlockingSet(b, 100, false) }
+func BenchmarkBlockingSet1000(b *testing.B)       { benchmarkBlockingSet(b, 1000, false) }
+func BenchmarkBlockingSetCached100(b *testing.B)  { benchmarkBlockingSet(b, 100, true) }
+func BenchmarkBlockingSetCached1000(b *testing.B) { benchmarkBlockingSet(b, 1000, true) }
+
+// BenchmarkBlockingSet2000 runs on a single core and on all of them, to show
+// how the computation of the BlockingSet scales.
+func BenchmarkBlockingSet2000(b *testing.B) {
+	procs := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		procs = append(procs, n)
+	}
+	for _, n := range procs {
+		b.Run(fmt.Sprintf("procs=%d", n), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(n))
+			benchmarkBlockingSet(b, 2000, false)
+		})
+	}
+}
+
+// benchmarkBlockingSet measures the cost of computing the BlockingSet after
+// a new tx, voted by all the validators, is added to a set of n txs.
+func benchmarkBlockingSet(b *testing.B, n int, cached bool) {
+	w := New()
+	vs := []Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	}
+	w.UpdateValidatorSet(vs)
+
+	prevVotes := make([]*Vote, len(vs))
+	addTx := func(seq int) {
+		tx := NewSimpleTx(
+			fmt.Sprintf("tx:%d", seq),
+			fmt.Sprintf("hash:%d", seq),
+		)
+		w.AddTx(tx)
+
+		for i, v := range vs {
+			vote := NewVote(Pubkey(v), uint64(seq), tx)
+			if pv := prevVotes[i]; pv != nil {
+				vote.WithPrevHash(pv.Hash())
+			}
+			prevVotes[i] = vote
+			_, err := w.AddVote(vote)
+			require.NoError(b, err)
+		}
+	}
+
+	for seq := 0; seq < n; seq++ {
+		addTx(seq)
+	}
+	if cached {
+		w.BlockingSetCached()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		addTx(n + i)
+		b.StartTimer()
+
+		if cached {
+			w.BlockingSetCached()
+		} else {
+			w.BlockingSet()
+		}
+	}
+}
+
+func BenchmarkPeerSeen10000(b *testing.B)   { benchmarkPeer(b, 10000, false) }
+func BenchmarkPeerBefore10000(b *testing.B) { benchmarkPeer(b, 10000, true) }
+
+// benchmarkPeer measures the cost of looking up the last tx voted by a peer
+// holding n votes.
+func benchmarkPeer(b *testing.B, n int, before bool) {
+	p := NewPeer(pub0)
+	txs := make([]Tx, 0, n)
+	var prevVote *Vote
+	for seq := 0; seq < n; seq++ {
+		tx := NewSimpleTx(
+			fmt.Sprintf("tx:%d", seq),
+			fmt.Sprintf("hash:%d", seq),
+		)
+		txs = append(txs, tx)
+
+		vote := NewVote(pub0, uint64(seq), tx)
+		if pv := prevVote; pv != nil {
+			vote.WithPrevHash(pv.Hash())
+		}
+		prevVote = vote
+		_, err := p.AddVote(vote)
+		require.NoError(b, err)
+	}
+
+	first, last := txs[0], txs[n-1]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if before {
+			p.Before(last, first)
+		} else {
+			p.Seen(last)
+		}
+	}
+}
+
+func BenchmarkVerifySerial10000(b *testing.B) { benchmarkVerify(b, 10000, false) }
+func BenchmarkVerifyBatch10000(b *testing.B)  { benchmarkVerify(b, 10000, true) }
+
+func benchmarkVerify(b *testing.B, n int, batch bool) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(b, err)
+
+	votes := make([]*SignedVote, n)
+	for i := range votes {
+		tx := NewSimpleTx(fmt.Sprintf("tx:%d", i), fmt.Sprintf("hash:%d", i))
+		votes[i] = NewSignedVote(priv, NewVote(Pubkey(pub), uint64(i), tx))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			for _, err := range VerifyBatch(votes) {
+				require.NoError(b, err)
+			}
+			continue
+		}
+		for _, sv := range votes {
+			require.NoError(b, sv.VerifyE())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package wendy
+
+// blockingDeltaBuffer is the capacity of the channels returned by
+// SubscribeBlockingChanges.
+const blockingDeltaBuffer = 64
+
+// BlockingDelta reports how the BlockingSet changed after a batch of votes.
+// Txs whose blocking txs didn't change are omitted, and so is every tx from
+// its own blocking txs.
+type BlockingDelta struct {
+	// Gained holds, by tx hash, the hashes of the txs blocking it that
+	// didn't block it before the batch, sorted.
+	Gained map[Hash][]Hash
+
+	// Lost holds, by tx hash, the hashes of the txs that blocked it before
+	// the batch but don't anymore, sorted.
+	Lost map[Hash][]Hash
+
+	// Dropped is the number of deltas dropped because the subscriber fell
+	// behind (see SubscribeBlockingChanges) that haven't been reported by an
+	// earlier delta, so that the sum of Dropped is the total dropped.
+	Dropped int
+}
+
+// Empty returns true if no tx gained nor lost blocking txs.
+func (d BlockingDelta) Empty() bool {
+	return len(d.Gained) == 0 && len(d.Lost) == 0
+}
+
+// blockingSubscription is a subscriber of SubscribeBlockingChanges.
+type blockingSubscription struct {
+	ch      chan BlockingDelta
+	dropped int
+}
+
+// SubscribeBlockingChanges returns a channel that receives a BlockingDelta
+// every time a batch of votes (i.e a call to AddVote, AddVotes, AddVoteBatch
+// or AddSignedVote) changes the BlockingSet, along with a function that
+// unsubscribes and closes the channel.
+// Adding votes never blocks on subscribers: the channel is buffered, and
+// when the buffer is full the oldest delta is dropped to make room for the
+// new one, which reports it in Dropped. Subscribers that get a delta with
+// Dropped > 0 should rebuild their view from BlockingSet.
+// While there are subscribers, the BlockingSet is computed before and after
+// every batch of votes.
+func (w *Wendy) SubscribeBlockingChanges() (<-chan BlockingDelta, func()) {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	sub := &blockingSubscription{
+		ch: make(chan BlockingDelta, blockingDeltaBuffer),
+	}
+	w.blockingSubs = append(w.blockingSubs, sub)
+
+	unsubscribe := func() {
+		w.peersMtx.Lock()
+		defer w.peersMtx.Unlock()
+
+		for i, s := range w.blockingSubs {
+			if s == sub {
+				w.blockingSubs = append(w.blockingSubs[:i], w.blockingSubs[i+1:]...)
+				close(sub.ch)
+				return
+			}
+		}
+	}
+	return sub.ch, unsubscribe
+}
+
+// publishBlockingDelta sends the delta to every subscriber, dropping their
+// oldest delta if their buffer is full.
+// The caller must hold the peersMtx write lock, so that deltas are sent in
+// order.
+func (w *Wendy) publishBlockingDelta(delta BlockingDelta) {
+	for _, sub := range w.blockingSubs {
+		sub.publish(delta)
+	}
+}
+
+func (sub *blockingSubscription) publish(delta BlockingDelta) {
+	for {
+		delta.Dropped = sub.dropped
+		select {
+		case sub.ch <- delta:
+			sub.dropped = 0
+			return
+		default:
+		}
+
+		// the buffer is full, drop the oldest delta unless the subscriber
+		// has just received it.
+		select {
+		case old := <-sub.ch:
+			sub.dropped += old.Dropped + 1
+		default:
+		}
+	}
+}
+
+// diffBlockingSets returns the blocking txs gained and lost by every tx from
+// prev to next.
+func diffBlockingSets(prev, next BlockingSet) BlockingDelta {
+	delta := BlockingDelta{
+		Gained: make(map[Hash][]Hash),
+		Lost:   make(map[Hash][]Hash),
+	}
+	for _, hash := range next.hashes() {
+		if gained := blockersDiff(hash, next[hash], prev[hash]); len(gained) > 0 {
+			delta.Gained[hash] = gained
+		}
+		if lost := blockersDiff(hash, prev[hash], next[hash]); len(lost) > 0 {
+			delta.Lost[hash] = lost
+		}
+	}
+	return delta
+}
+
+// blockersDiff returns the sorted hashes of the txs in a but not in b,
+// excluding self.
+func blockersDiff(self Hash, a, b []Tx) []Hash {
+	var diff []Hash
+	for _, tx := range a {
+		if h := tx.Hash(); h != self && !containsTx(b, h) {
+			diff = append(diff, h)
+		}
+	}
+	sortHashes(diff)
+	return diff
+}
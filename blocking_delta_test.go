@@ -0,0 +1,59 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeBlockingChanges(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+	w.AddTx(testTx0)
+	w.AddTx(testTx1)
+
+	ch, unsubscribe := w.SubscribeBlockingChanges()
+
+	// without votes both txs block each other.
+	var votes []*Vote
+	for _, pub := range []Pubkey{pub0, pub1, pub2} {
+		v0 := NewVote(pub, 0, testTx0)
+		votes = append(votes, v0, NewVote(pub, 1, testTx1).WithPrevHash(v0.Hash()))
+	}
+	require.NoError(t, w.AddVotes(votes...))
+
+	select {
+	case delta := <-ch:
+		assert.Equal(t, map[Hash][]Hash{testTx0.Hash(): {testTx1.Hash()}}, delta.Lost)
+		assert.Empty(t, delta.Gained)
+		assert.Zero(t, delta.Dropped)
+	default:
+		t.Fatal("expected a delta")
+	}
+
+	// votes that don't change the BlockingSet don't produce a delta.
+	_, err := w.AddVote(NewVote(pub3, 0, testTx0))
+	require.NoError(t, err)
+	assert.Len(t, ch, 0)
+
+	unsubscribe()
+	_, ok := <-ch
+	assert.False(t, ok, "the channel is closed")
+	assert.NotPanics(t, unsubscribe)
+
+	t.Run("DropOldest", func(t *testing.T) {
+		sub := &blockingSubscription{ch: make(chan BlockingDelta, 2)}
+		for i := 0; i < 5; i++ {
+			sub.publish(BlockingDelta{Gained: map[Hash][]Hash{
+				testTx0.Hash(): make([]Hash, i),
+			}})
+		}
+
+		// the 3 oldest deltas have been dropped.
+		first, second := <-sub.ch, <-sub.ch
+		assert.Len(t, first.Gained[testTx0.Hash()], 3)
+		assert.Len(t, second.Gained[testTx0.Hash()], 4)
+		assert.Equal(t, 3, first.Dropped+second.Dropped)
+	})
+}
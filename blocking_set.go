@@ -0,0 +1,442 @@
+package wendy
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// ErrFairnessLoop is returned when txs can't be totally ordered because they
+// form a fairness loop.
+var ErrFairnessLoop = errors.New("fairness loop")
+
+// selectTxs selects the txs to be included in a block given the options,
+// sorted by hash, see selection.
+func (set BlockingSet) selectTxs(opts NewBlockOptions) []Tx {
+	txs := []Tx{}
+	set.selection(opts, func(tx Tx) {
+		txs = append(txs, tx)
+	})
+	sortTxs(txs)
+	return txs
+}
+
+// BlockStats returns the number of txs and the sum of their sizes of the
+// block that would be produced given the options, without building it.
+// Sizes include opts.PerTxOverhead, as they do for opts.MaxBlockSize.
+// The selection is the same NewBlockWithOptions does, except for the options
+// that depend on Wendy's state, which are ignored: opts.MinTxs,
+// opts.PreferOlderRounds and the fairness timeout (see WithFairnessTimeout),
+// hence the stats can differ from the actual block when they are used, see
+// Wendy.BlockStats. opts.AddBlock is ignored as well.
+func (set BlockingSet) BlockStats(opts NewBlockOptions) (numTxs int, totalBytes int) {
+	set.selection(opts, func(tx Tx) {
+		numTxs++
+		totalBytes += opts.txSize(tx)
+	})
+	return numTxs, totalBytes
+}
+
+// selection calls fn for every tx to be included in a block given the
+// options.
+// A tx is selected along with all its blocking txs. Txs blocking more txs
+// are selected first and ties are broken by hash order, unless
+// opts.PreferOlderRounds is set, in which case txs are selected by round
+// first, or opts.Priority is set, which takes precedence over both.
+// Selection stops as soon as including the next tx, along with its blocking
+// txs, would exceed either opts.TxLimit or opts.MaxBlockSize.
+// Txs in opts.Exclude are never selected nor accounted.
+// Txs in opts.MustInclude, along with their blocking txs, are selected before
+// any other regardless of the filter and limits, but they are accounted.
+// Txs forced by the fairness timeout (see WithFairnessTimeout) are selected
+// before the rest of the candidates.
+func (set BlockingSet) selection(opts NewBlockOptions, fn func(Tx)) {
+	byHash := set.txsByHash()
+
+	var (
+		selected = make(map[Hash]struct{})
+		size     int
+	)
+
+	// missing returns the txs to be included along with hash that are not
+	// selected yet, and the sum of their sizes.
+	missing := func(hash Hash) (txs []Tx, txsSize int) {
+		for _, tx := range set[hash] {
+			// excluded txs are considered as already included.
+			if opts.Exclude[tx.Hash()] {
+				continue
+			}
+			if _, ok := selected[tx.Hash()]; !ok {
+				txs = append(txs, tx)
+				txsSize += opts.txSize(tx)
+			}
+		}
+		if _, ok := selected[hash]; !ok && !containsTx(txs, hash) {
+			txs = append(txs, byHash[hash])
+			txsSize += opts.txSize(byHash[hash])
+		}
+		return txs, txsSize
+	}
+	// filtered returns true if either the tx or any of the blocking txs to be
+	// included along with it doesn't pass the label filter.
+	filtered := func(hash Hash) bool {
+		filter := opts.LabelFilter
+		if filter == nil {
+			return false
+		}
+		if !filter(byHash[hash].Label()) {
+			return true
+		}
+		for _, tx := range set[hash] {
+			if _, ok := selected[tx.Hash()]; ok || opts.Exclude[tx.Hash()] {
+				continue
+			}
+			if !filter(tx.Label()) {
+				return true
+			}
+		}
+		return false
+	}
+	include := func(txs []Tx, txsSize int) {
+		for _, tx := range txs {
+			selected[tx.Hash()] = struct{}{}
+			fn(tx)
+		}
+		size += txsSize
+	}
+
+	for _, hash := range opts.MustInclude {
+		if _, ok := set[hash]; !ok || opts.Exclude[hash] {
+			continue
+		}
+		include(missing(hash))
+	}
+	if opts.mustOnly {
+		return
+	}
+
+	// dependants counts how many txs are blocked by a given tx.
+	dependants := make(map[Hash]int)
+	for hash, txs := range set {
+		for _, tx := range txs {
+			if h := tx.Hash(); h != hash {
+				dependants[h]++
+			}
+		}
+	}
+
+	candidates := set.hashes()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return dependants[candidates[i]] > dependants[candidates[j]]
+	})
+	if rounds := opts.rounds; rounds != nil {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			ri, oki := rounds[candidates[i]]
+			rj, okj := rounds[candidates[j]]
+			if !oki || !okj {
+				return oki && !okj
+			}
+			return ri < rj
+		})
+	}
+	if less := opts.Priority; less != nil {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return less(byHash[candidates[i]], byHash[candidates[j]])
+		})
+	}
+	if forced := opts.forced; forced != nil {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return forced[candidates[i]] && !forced[candidates[j]]
+		})
+	}
+
+	for _, hash := range candidates {
+		// declared dependencies (see AddTxWithDeps) can cross labels, so the
+		// blocking txs are filtered as well.
+		if filtered(hash) {
+			continue
+		}
+		if opts.Exclude[hash] {
+			continue
+		}
+
+		txs, txsSize := missing(hash)
+		if limit := opts.TxLimit; limit > 0 && len(selected)+len(txs) > limit {
+			break
+		}
+
+		if max := opts.MaxBlockSize; max > 0 && size+txsSize > max {
+			break
+		}
+
+		include(txs, txsSize)
+	}
+}
+
+// cyclic returns the hashes of txs that are part of a fairness loop (see
+// Cycles) or nil if there are none.
+func (set BlockingSet) cyclic(txs []Tx) map[Hash]bool {
+	var inLoop map[Hash]bool
+	for _, cycle := range set.Cycles() {
+		for _, hash := range cycle {
+			if containsTx(txs, hash) {
+				if inLoop == nil {
+					inLoop = make(map[Hash]bool)
+				}
+				inLoop[hash] = true
+			}
+		}
+	}
+	return inLoop
+}
+
+func containsTx(txs []Tx, hash Hash) bool {
+	for _, tx := range txs {
+		if tx.Hash() == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// hashes returns the hashes of all the txs in the set sorted.
+func (set BlockingSet) hashes() []Hash {
+	hashes := make([]Hash, 0, len(set))
+	for hash := range set {
+		hashes = append(hashes, hash)
+	}
+	sortHashes(hashes)
+	return hashes
+}
+
+// Filter returns a new BlockingSet holding only the txs for which keep
+// returns true, the blocking txs that are dropped are removed from the
+// blocking txs of the kept ones.
+// The filtered set can be used to build a block out of a subset of the txs,
+// see Wendy.NewBlockFromSet.
+func (set BlockingSet) Filter(keep func(Hash) bool) BlockingSet {
+	filtered := make(BlockingSet)
+	for hash, txs := range set {
+		if !keep(hash) {
+			continue
+		}
+
+		blockers := make([]Tx, 0, len(txs))
+		for _, tx := range txs {
+			if keep(tx.Hash()) {
+				blockers = append(blockers, tx)
+			}
+		}
+		filtered[hash] = blockers
+	}
+	return filtered
+}
+
+// Edges returns every (dependent, dependency) pair of the set, i.e the pairs
+// of txs where the first one is blocked by the second one.
+// Since the set holds all the txs transitively blocking a tx, so do the
+// edges. Self-edges (every tx is part of its own blocking txs) are excluded.
+// Edges are de-duplicated and sorted by dependent and then by dependency.
+func (set BlockingSet) Edges() [][2]Hash {
+	var edges [][2]Hash
+	for _, hash := range set.hashes() {
+		blockers := make([]Hash, 0, len(set[hash]))
+		seen := make(map[Hash]struct{}, len(set[hash]))
+		for _, tx := range set[hash] {
+			h := tx.Hash()
+			if _, ok := seen[h]; ok || h == hash {
+				continue
+			}
+			seen[h] = struct{}{}
+			blockers = append(blockers, h)
+		}
+		sortHashes(blockers)
+
+		for _, blocker := range blockers {
+			edges = append(edges, [2]Hash{hash, blocker})
+		}
+	}
+	return edges
+}
+
+// Cycles returns the groups of txs that form a fairness loop, i.e the
+// strongly connected components of the blocking graph.
+// Txs that are not part of a loop are omitted.
+// Hashes within a group are sorted, and so are the groups by their first hash.
+func (set BlockingSet) Cycles() [][]Hash {
+	var cycles [][]Hash
+	for _, scc := range set.components() {
+		if len(scc) > 1 {
+			cycles = append(cycles, scc)
+		}
+	}
+	return cycles
+}
+
+// Order returns the txs of the set in dependency order, meaning that a tx is
+// placed after all the txs blocking it. Ties are broken by hash order.
+// When txs form a fairness loop, the txs of the loop are placed adjacently in
+// hash order and ErrFairnessLoop is returned along with the order.
+// The set only holds the closure of the blocking relations, so the declared
+// dependencies (see AddTxWithDeps) within a fairness loop are lost, use
+// Wendy.Order to honour them.
+func (set BlockingSet) Order() ([]Tx, error) {
+	return set.order(nil)
+}
+
+// order is the implementation of Order, the txs of every fairness loop, in
+// hash order, are reordered by sortLoop unless it's nil.
+func (set BlockingSet) order(sortLoop func([]Tx) []Tx) ([]Tx, error) {
+	byHash := set.txsByHash()
+	comps := set.components()
+
+	// compOf maps a tx hash to its component index.
+	compOf := make(map[Hash]int)
+	for i, comp := range comps {
+		for _, hash := range comp {
+			compOf[hash] = i
+		}
+	}
+
+	// deps holds, for each component, the set of components it depends on.
+	deps := make([]map[int]struct{}, len(comps))
+	for i, comp := range comps {
+		deps[i] = make(map[int]struct{})
+		for _, hash := range comp {
+			for _, tx := range set[hash] {
+				if j, ok := compOf[tx.Hash()]; ok && j != i {
+					deps[i][j] = struct{}{}
+				}
+			}
+		}
+	}
+
+	var (
+		err   error
+		order = make([]Tx, 0, len(byHash))
+		done  = make([]bool, len(comps))
+	)
+	// Components are sorted by their first hash, thus, picking the first
+	// component with all its dependencies emitted breaks ties by hash order.
+	for emitted := 0; emitted < len(comps); emitted++ {
+		for i, comp := range comps {
+			if done[i] || !allDone(deps[i], done) {
+				continue
+			}
+
+			txs := make([]Tx, len(comp))
+			for j, hash := range comp {
+				txs[j] = byHash[hash]
+			}
+			if len(comp) > 1 {
+				err = ErrFairnessLoop
+				if sortLoop != nil {
+					txs = sortLoop(txs)
+				}
+			}
+			order = append(order, txs...)
+			done[i] = true
+			break
+		}
+	}
+
+	return order, err
+}
+
+func allDone(deps map[int]struct{}, done []bool) bool {
+	for i := range deps {
+		if !done[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// txsByHash returns all the txs in the set indexed by their hash.
+func (set BlockingSet) txsByHash() map[Hash]Tx {
+	byHash := make(map[Hash]Tx, len(set))
+	for _, txs := range set {
+		for _, tx := range txs {
+			byHash[tx.Hash()] = tx
+		}
+	}
+	return byHash
+}
+
+// components returns all the strongly connected components of the blocking
+// graph (including single tx components) using Tarjan's algorithm.
+func (set BlockingSet) components() [][]Hash {
+	t := &tarjan{
+		set:     set,
+		index:   make(map[Hash]int),
+		lowlink: make(map[Hash]int),
+		onStack: make(map[Hash]bool),
+	}
+
+	for _, hash := range set.hashes() {
+		if _, ok := t.index[hash]; !ok {
+			t.strongConnect(hash)
+		}
+	}
+
+	sort.Slice(t.sccs, func(i, j int) bool {
+		return bytes.Compare(t.sccs[i][0][:], t.sccs[j][0][:]) < 0
+	})
+	return t.sccs
+}
+
+// tarjan holds the state of Tarjan's strongly connected components algorithm.
+type tarjan struct {
+	set     BlockingSet
+	counter int
+	index   map[Hash]int
+	lowlink map[Hash]int
+	onStack map[Hash]bool
+	stack   []Hash
+	sccs    [][]Hash
+}
+
+func (t *tarjan) strongConnect(v Hash) {
+	t.index[v] = t.counter
+	t.lowlink[v] = t.counter
+	t.counter++
+	t.stack = append(t.stack, v)
+	t.onStack[v] = true
+
+	for _, tx := range t.set[v] {
+		w := tx.Hash()
+		// txs that are not part of the set are ignored.
+		if _, ok := t.set[w]; !ok {
+			continue
+		}
+
+		if _, ok := t.index[w]; !ok {
+			t.strongConnect(w)
+			if t.lowlink[w] < t.lowlink[v] {
+				t.lowlink[v] = t.lowlink[w]
+			}
+		} else if t.onStack[w] {
+			if t.index[w] < t.lowlink[v] {
+				t.lowlink[v] = t.index[w]
+			}
+		}
+	}
+
+	if t.lowlink[v] != t.index[v] {
+		return
+	}
+
+	var scc []Hash
+	for {
+		n := len(t.stack) - 1
+		w := t.stack[n]
+		t.stack = t.stack[:n]
+		t.onStack[w] = false
+		scc = append(scc, w)
+		if w == v {
+			break
+		}
+	}
+	sortHashes(scc)
+	t.sccs = append(t.sccs, scc)
+}
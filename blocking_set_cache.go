@@ -0,0 +1,170 @@
+package wendy
+
+// blockingSetCache keeps the blocking graph and the BlockingSet computed from
+// it, so that they can be updated incrementally only for the txs affected
+// by the changes made since the last update.
+type blockingSetCache struct {
+	// blockedBy holds, for every tx, the hashes of the txs blocking it.
+	blockedBy map[Hash]map[Hash]struct{}
+	set       BlockingSet
+
+	// dirty holds the txs that have changed since the last update.
+	dirty map[Hash]struct{}
+}
+
+func newBlockingSetCache() *blockingSetCache {
+	return &blockingSetCache{
+		blockedBy: make(map[Hash]map[Hash]struct{}),
+		set:       BlockingSet{},
+		dirty:     make(map[Hash]struct{}),
+	}
+}
+
+// invalidateBlockingSet marks the given txs as changed so that their
+// blocking state is recomputed by the next BlockingSetCached call.
+// When called without hashes, the whole cache is invalidated.
+func (w *Wendy) invalidateBlockingSet(hashes ...Hash) {
+	w.cacheMtx.Lock()
+	defer w.cacheMtx.Unlock()
+
+	if w.cache == nil {
+		return
+	}
+
+	if len(hashes) == 0 {
+		w.cache = nil
+		return
+	}
+
+	for _, hash := range hashes {
+		w.cache.dirty[hash] = struct{}{}
+	}
+}
+
+// BlockingSetCached returns the same result as BlockingSet, but instead of
+// computing it from scratch, it keeps a cached blocking graph which is
+// updated only for the txs affected by the txs and votes added or removed
+// since the last call.
+func (w *Wendy) BlockingSetCached() BlockingSet {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	w.cacheMtx.Lock()
+	defer w.cacheMtx.Unlock()
+
+	if w.cache == nil {
+		w.cache = newBlockingSetCache()
+		for _, tx := range w.txs.List() {
+			w.cache.dirty[tx.Hash()] = struct{}{}
+		}
+	}
+	w.cache.update(w)
+
+	// blockers are never modified in place, so a shallow copy is enough.
+	set := make(BlockingSet, len(w.cache.set))
+	for hash, blockers := range w.cache.set {
+		set[hash] = blockers
+	}
+	return set
+}
+
+// update recomputes the blocking graph for the dirty txs and the BlockingSet
+// entries affected by the changes on the graph.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (c *blockingSetCache) update(w *Wendy) {
+	if len(c.dirty) == 0 {
+		return
+	}
+
+	// changed holds the txs whose set of blocking txs has changed.
+	changed := make(map[Hash]struct{})
+	for hash := range c.dirty {
+		delete(c.blockedBy, hash)
+		delete(c.set, hash)
+		changed[hash] = struct{}{}
+		for h, blockers := range c.blockedBy {
+			if _, ok := blockers[hash]; ok {
+				delete(blockers, hash)
+				changed[h] = struct{}{}
+			}
+		}
+
+		tx := w.txs.ByHash(hash)
+		if tx == nil {
+			continue
+		}
+
+		for _, tx2 := range w.txs.List() {
+			hash2 := tx2.Hash()
+			if hash2 == hash {
+				continue
+			}
+
+			if w.blockedBy(tx, tx2) {
+				c.addEdge(hash, hash2)
+			}
+			if w.blockedBy(tx2, tx) {
+				c.addEdge(hash2, hash)
+				changed[hash2] = struct{}{}
+			}
+		}
+	}
+
+	affected := make(map[Hash]struct{})
+	for hash := range c.dirty {
+		if w.txs.ByHash(hash) != nil {
+			affected[hash] = struct{}{}
+		}
+	}
+	for hash, blockers := range c.set {
+		for _, tx := range blockers {
+			if _, ok := changed[tx.Hash()]; ok {
+				affected[hash] = struct{}{}
+				break
+			}
+		}
+	}
+
+	for hash := range affected {
+		c.set[hash] = c.blockers(w.txs, hash)
+	}
+	c.dirty = make(map[Hash]struct{})
+}
+
+// addEdge registers that the tx identified by hash is blocked by the tx
+// identified by blocker.
+func (c *blockingSetCache) addEdge(hash, blocker Hash) {
+	blockers, ok := c.blockedBy[hash]
+	if !ok {
+		blockers = make(map[Hash]struct{})
+		c.blockedBy[hash] = blockers
+	}
+	blockers[blocker] = struct{}{}
+}
+
+// blockers returns the txs that transitively block the tx identified by
+// hash, including the tx itself, sorted by hash.
+func (c *blockingSetCache) blockers(txs *Txs, hash Hash) []Tx {
+	visited := map[Hash]struct{}{hash: {}}
+	stack := []Hash{hash}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for blocker := range c.blockedBy[h] {
+			if _, ok := visited[blocker]; !ok {
+				visited[blocker] = struct{}{}
+				stack = append(stack, blocker)
+			}
+		}
+	}
+
+	list := make([]Tx, 0, len(visited))
+	for h := range visited {
+		list = append(list, txs.ByHash(h))
+	}
+	sortTxs(list)
+	return list
+}
@@ -0,0 +1,273 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fairnessLoopTxsMap and fullyAgreeTxsMap are the votes used by the
+// FairnessLoop and FullyAgree cases of TestBlockingSet.
+var (
+	fairnessLoopTxsMap = map[ID][]Tx{
+		"0x00": {testTx1, testTx2, testTx3, testTx4, testTx5},
+		"0x01": {testTx2, testTx3, testTx4, testTx5, testTx1},
+		"0x02": {testTx3, testTx4, testTx5, testTx1, testTx2},
+		"0x03": {testTx4, testTx5, testTx1, testTx2, testTx3},
+		"0x04": {testTx5, testTx1, testTx2, testTx3, testTx4},
+	}
+
+	fullyAgreeTxsMap = map[ID][]Tx{
+		"0x00": {testTx1, testTx2, testTx3, testTx4, testTx5},
+		"0x01": {testTx1, testTx2, testTx3, testTx4, testTx5},
+		"0x02": {testTx1, testTx2, testTx3, testTx4, testTx5},
+		"0x03": {testTx1, testTx2, testTx3, testTx4, testTx5},
+		"0x04": {testTx1, testTx2, testTx3, testTx4, testTx5},
+	}
+)
+
+func TestBlockingSetCycles(t *testing.T) {
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+
+		cycles := w.BlockingSet().Cycles()
+		assert.Equal(t, [][]Hash{
+			{testTx1.Hash(), testTx2.Hash(), testTx3.Hash(), testTx4.Hash(), testTx5.Hash()},
+		}, cycles)
+
+		// every validator votes testTx1 one position earlier than the previous
+		// one.
+		assert.Equal(t, map[ID]uint64{
+			"0x00": 0, "0x01": 4, "0x02": 3, "0x03": 2, "0x04": 1,
+		}, w.TxPositions(testTx1))
+		assert.Empty(t, w.TxPositions(testTx0))
+	})
+
+	t.Run("FullyAgree", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+		assert.Empty(t, w.BlockingSet().Cycles())
+	})
+}
+
+func TestBlockingSetBlockers(t *testing.T) {
+	// txs are added in hash order, but voted in reverse, so the blockers of
+	// every tx are the txs added after it, not the first ones.
+	w := New()
+	for _, tx := range []Tx{testTx0, testTx1, testTx2} {
+		w.AddTx(tx)
+	}
+	pubs := []Pubkey{pub0, pub1, pub2}
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+	for _, pub := range pubs {
+		var prev *Vote
+		for seq, tx := range []Tx{testTx2, testTx1, testTx0} {
+			v := NewVote(pub, uint64(seq), tx)
+			if prev != nil {
+				v.WithPrevHash(prev.Hash())
+			}
+			assert.NoError(t, w.AddVotes(v))
+			prev = v
+		}
+	}
+
+	set := w.BlockingSet()
+	assert.Equal(t, []Tx{testTx2}, set[testTx2.Hash()])
+	assert.Equal(t, []Tx{testTx1, testTx2}, set[testTx1.Hash()])
+	assert.Equal(t, []Tx{testTx0, testTx1, testTx2}, set[testTx0.Hash()])
+}
+
+func TestBlockingSetEdges(t *testing.T) {
+	w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+	set := w.BlockingSet()
+	// add a duplicated blocker, which must be reported once.
+	set[testTx2.Hash()] = append(set[testTx2.Hash()], testTx1)
+
+	edges := set.Edges()
+	assert.Equal(t, [][2]Hash{
+		{testTx2.Hash(), testTx1.Hash()},
+		{testTx3.Hash(), testTx1.Hash()},
+		{testTx3.Hash(), testTx2.Hash()},
+	}, edges[:3])
+	for _, edge := range edges {
+		assert.NotEqual(t, edge[0], edge[1], "self-edges are excluded")
+	}
+
+	assert.Empty(t, BlockingSet{testTx0.Hash(): {testTx0}}.Edges())
+}
+
+func TestBlockingSetFilter(t *testing.T) {
+	w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+	set := w.BlockingSet()
+
+	keep := map[Hash]bool{
+		testTx1.Hash(): true, testTx3.Hash(): true, testTx5.Hash(): true,
+	}
+	filtered := set.Filter(func(h Hash) bool { return keep[h] })
+
+	assert.Len(t, filtered, 3)
+	assert.Equal(t, []Tx{testTx1}, filtered[testTx1.Hash()])
+	assert.Equal(t, []Tx{testTx1, testTx3}, filtered[testTx3.Hash()])
+	assert.Equal(t, []Tx{testTx1, testTx3, testTx5}, filtered[testTx5.Hash()])
+	assert.Len(t, set, 5, "the original set is not modified")
+
+	block := w.NewBlockFromSet(filtered, NewBlockOptions{TxLimit: 2})
+	assert.Equal(t, []Tx{testTx1, testTx3}, block.Txs)
+}
+
+func TestPreferredOrder(t *testing.T) {
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+		cycles := w.BlockingSet().Cycles()
+		assert.Len(t, cycles, 1)
+
+		// every tx is reported before the next two txs of the loop by a
+		// majority, so they all score the same.
+		assert.Equal(t, cycles[0], w.PreferredOrder(cycles[0]))
+	})
+
+	t.Run("Winner", func(t *testing.T) {
+		// testTx3 is reported before the rest by a majority, and testTx2
+		// before testTx1, but not all of them by a quorum (4 out of 5).
+		w := newWendyFromTxsMap(t, map[ID][]Tx{
+			"0x00": {testTx3, testTx2, testTx1},
+			"0x01": {testTx3, testTx2, testTx1},
+			"0x02": {testTx3, testTx1, testTx2},
+			"0x03": {testTx2, testTx3, testTx1},
+			"0x04": {testTx1, testTx2, testTx3},
+		})
+		cycles := w.BlockingSet().Cycles()
+		assert.Equal(t, [][]Hash{{testTx1.Hash(), testTx2.Hash(), testTx3.Hash()}}, cycles)
+
+		order := w.PreferredOrder(cycles[0])
+		assert.Equal(t, []Hash{testTx3.Hash(), testTx2.Hash(), testTx1.Hash()}, order)
+
+		unknown := Checksum([]byte("unknown"))
+		order = w.PreferredOrder(append([]Hash{unknown}, cycles[0]...))
+		assert.Equal(t, unknown, order[3], "unknown txs are placed last")
+	})
+}
+
+func TestBlockingSetOrder(t *testing.T) {
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+
+		order, err := w.BlockingSet().Order()
+		assert.ErrorIs(t, err, ErrFairnessLoop)
+		assert.Equal(t, []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}, order)
+	})
+
+	t.Run("FullyAgree", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, map[ID][]Tx{
+			"0x00": {testTx3, testTx1, testTx5, testTx2, testTx4},
+			"0x01": {testTx3, testTx1, testTx5, testTx2, testTx4},
+			"0x02": {testTx3, testTx1, testTx5, testTx2, testTx4},
+		})
+
+		order, err := w.BlockingSet().Order()
+		assert.NoError(t, err)
+		assert.Equal(t, []Tx{testTx3, testTx1, testTx5, testTx2, testTx4}, order)
+	})
+}
+
+func TestBlockingSetCached(t *testing.T) {
+	assertCached := func(t *testing.T, w *Wendy) {
+		t.Helper()
+		assert.Equal(t, w.BlockingSet(), w.BlockingSetCached())
+	}
+
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+		assertCached(t, w)
+
+		w.AddTx(testTx0)
+		assertCached(t, w)
+
+		w.RemoveTx(testTx3.Hash())
+		assertCached(t, w)
+
+		w.CommitBlock(Block{Txs: []Tx{testTx1, testTx2}})
+		assertCached(t, w)
+	})
+
+	t.Run("Incremental", func(t *testing.T) {
+		pubs := []Pubkey{pub0, pub1, pub2, pub3}
+		w := New()
+		for _, pub := range pubs {
+			w.AddValidator(pub.Bytes())
+		}
+
+		txs := []Tx{testTx0, testTx1, testTx2, testTx3, testTx4}
+		prevs := make(map[string]*Vote)
+		for i, tx := range txs {
+			w.AddTx(tx)
+			assertCached(t, w)
+
+			// pub3 votes the txs in reverse order.
+			for j, pub := range pubs {
+				seq := uint64(i)
+				if j == 3 {
+					seq = uint64(len(txs) - 1 - i)
+				}
+				vote := NewVote(pub, seq, tx)
+				if prev := prevs[pub.String()]; prev != nil && j != 3 {
+					vote.WithPrevHash(prev.Hash())
+				}
+				prevs[pub.String()] = vote
+
+				_, err := w.AddVote(vote)
+				assert.NoError(t, err)
+				assertCached(t, w)
+			}
+		}
+
+		w.RemoveValidator(pub3.Bytes())
+		assertCached(t, w)
+
+		w.CommitBlock(Block{Txs: []Tx{testTx0}})
+		assertCached(t, w)
+	})
+}
+
+func TestBlockStats(t *testing.T) {
+	w := newWendyFromTxsMap(t, map[ID][]Tx{
+		"0x00": {testTx0, testTx1, testTx2, testTx3, testTx4},
+	})
+	set := w.BlockingSet()
+
+	for _, opts := range []NewBlockOptions{
+		{},
+		{TxLimit: 2},
+		{MaxBlockSize: 10},
+		{TxLimit: 4, MaxBlockSize: 10},
+		{MaxBlockSize: 10, PerTxOverhead: 2},
+		{LabelFilter: func(string) bool { return false }},
+	} {
+		block := w.NewBlockWithOptions(opts)
+
+		var size int
+		for _, tx := range block.Txs {
+			size += len(tx.Bytes()) + opts.PerTxOverhead
+		}
+
+		numTxs, totalBytes := set.BlockStats(opts)
+		assert.Equal(t, len(block.Txs), numTxs)
+		assert.Equal(t, size, totalBytes)
+
+		numTxs, totalBytes = w.BlockStats(opts)
+		assert.Equal(t, len(block.Txs), numTxs)
+		assert.Equal(t, size, totalBytes)
+	}
+
+	t.Run("WendyState", func(t *testing.T) {
+		// there are not enough unblocked txs, so the block is empty, which
+		// only Wendy can tell.
+		opts := NewBlockOptions{MinTxs: 10}
+		assert.Empty(t, w.NewBlockWithOptions(opts).Txs)
+
+		numTxs, _ := w.BlockStats(opts)
+		assert.Zero(t, numTxs)
+
+		numTxs, _ = set.BlockStats(opts)
+		assert.NotZero(t, numTxs)
+	})
+}
@@ -0,0 +1,108 @@
+package wendy
+
+import "sort"
+
+// QuorumCert proves that a quorum of validators has seen a tx (see
+// Peer.Seen), it holds one signed vote per validator.
+type QuorumCert struct {
+	TxHash Hash
+
+	// Quorum is the voting weight required to reach quorum at the time the
+	// certificate was produced.
+	Quorum uint64
+	Votes  []*SignedVote
+}
+
+// Verify verifies the certificate given the voting weight of the validators,
+// indexed by their ID. A nil weights map gives every validator a weight of 1.
+// It returns true if all the votes are correctly signed, are for the
+// certificate's tx and come from different validators, and their weight adds
+// up to the quorum.
+func (qc *QuorumCert) Verify(weights map[ID]uint64) bool {
+	if qc.Quorum == 0 {
+		return false
+	}
+
+	var total uint64
+	seen := make(map[ID]struct{}, len(qc.Votes))
+	for _, sv := range qc.Votes {
+		if sv.Data == nil || sv.Data.TxHash != qc.TxHash || !sv.Verify() {
+			return false
+		}
+
+		id := sv.Data.Key()
+		if _, ok := seen[id]; ok {
+			return false
+		}
+		seen[id] = struct{}{}
+
+		if weights == nil {
+			total++
+		} else {
+			total += weights[id]
+		}
+	}
+	return total >= qc.Quorum
+}
+
+// Certificate returns a QuorumCert for tx made of the signed votes added via
+// AddSignedVote. Votes are picked in ID order until the quorum is reached.
+// It returns false if there are not enough signed votes to reach the quorum,
+// note that unsigned votes (added via AddVote) are counted by IsBlocked but
+// can't be part of a certificate.
+func (w *Wendy) Certificate(tx Tx) (*QuorumCert, bool) {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	if w.quorum == 0 {
+		return nil, false
+	}
+
+	hash := tx.Hash()
+	signed := w.signatures[hash]
+	ids := make([]string, 0, len(signed))
+	for id := range signed {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	qc := &QuorumCert{TxHash: hash, Quorum: w.quorum}
+	var total uint64
+	for _, id := range ids {
+		peer, ok := w.peers[ID(id)]
+		if !ok {
+			continue
+		}
+		if _, ok := w.stale[ID(id)]; ok || !peer.Seen(tx) {
+			continue
+		}
+
+		qc.Votes = append(qc.Votes, signed[ID(id)])
+		total += peer.Weight()
+		if total >= w.quorum {
+			return qc, true
+		}
+	}
+	return nil, false
+}
+
+// addSignature registers the signed vote so that it can be part of a
+// certificate, as long as it is the vote the peer has for the tx.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) addSignature(sv *SignedVote) {
+	v := sv.Data
+	peer, ok := w.peers[v.Key()]
+	if !ok {
+		return
+	}
+	if stored := peer.vote(v.Label, v.TxHash); stored == nil || stored.Hash() != v.Hash() {
+		return
+	}
+
+	signed, ok := w.signatures[v.TxHash]
+	if !ok {
+		signed = make(map[ID]*SignedVote)
+		w.signatures[v.TxHash] = signed
+	}
+	signed[v.Key()] = sv
+}
@@ -0,0 +1,65 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificate(t *testing.T) {
+	keys := make([]ed25519.PrivateKey, 4)
+	var vs []Validator
+	for i := range keys {
+		pub, key, err := ed25519.GenerateKey(Rand)
+		require.NoError(t, err)
+		keys[i] = key
+		vs = append(vs, Validator(pub))
+	}
+
+	w := New()
+	w.UpdateValidatorSet(vs)
+	w.AddTx(testTx0)
+
+	signed := func(i int) *SignedVote {
+		return NewSignedVote(keys[i], NewVote(Pubkey(vs[i]), 0, testTx0))
+	}
+
+	// quorum is 3, unsigned votes are not part of the certificate.
+	_, err := w.AddVote(NewVote(Pubkey(vs[0]), 0, testTx0))
+	require.NoError(t, err)
+	for _, i := range []int{1, 2} {
+		_, err := w.AddSignedVote(signed(i))
+		require.NoError(t, err)
+	}
+	assert.False(t, w.IsBlocked(testTx0))
+	_, ok := w.Certificate(testTx0)
+	assert.False(t, ok)
+
+	_, err = w.AddSignedVote(signed(3))
+	require.NoError(t, err)
+	qc, ok := w.Certificate(testTx0)
+	require.True(t, ok)
+	assert.Equal(t, testTx0.Hash(), qc.TxHash)
+	assert.Equal(t, uint64(3), qc.Quorum)
+	assert.Len(t, qc.Votes, 3)
+	assert.True(t, qc.Verify(nil))
+
+	t.Run("Verify", func(t *testing.T) {
+		weights := map[ID]uint64{qc.Votes[0].Data.Key(): 1}
+		assert.False(t, qc.Verify(weights), "unknown validators have no weight")
+
+		dup := *qc
+		dup.Votes = []*SignedVote{qc.Votes[0], qc.Votes[0], qc.Votes[1]}
+		assert.False(t, dup.Verify(nil), "duplicated votes")
+
+		tampered := *qc
+		tampered.TxHash = testTx1.Hash()
+		assert.False(t, tampered.Verify(nil))
+	})
+
+	w.CommitBlock(Block{Txs: []Tx{testTx0}})
+	_, ok = w.Certificate(testTx0)
+	assert.False(t, ok)
+}
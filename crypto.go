@@ -1,6 +1,7 @@
 package wendy
 
 import (
+	"crypto/subtle"
 	"encoding/hex"
 	"math/rand"
 	"strings"
@@ -19,10 +20,7 @@ type Pubkey []byte
 // a Pubkey.
 // If the format of s is not hex encoded it panics.
 func NewPubkeyFromID(id ID) Pubkey {
-	s := string(id)
-	s = strings.TrimPrefix(s, "0x")
-
-	enc, err := hex.DecodeString(s)
+	enc, err := parsePubkey(id)
 	if err != nil {
 		panic(err)
 	}
@@ -30,7 +28,19 @@ func NewPubkeyFromID(id ID) Pubkey {
 	return enc
 }
 
+// parsePubkey is the non panicking version of NewPubkeyFromID.
+func parsePubkey(id ID) (Pubkey, error) {
+	return hex.DecodeString(strings.TrimPrefix(string(id), "0x"))
+}
+
 // String returns the string representation of the public key. This is hex
 // encoded and 0x prefixed.
 func (pk Pubkey) String() string { return "0x" + hex.EncodeToString(pk) }
 func (pk Pubkey) Bytes() []byte  { return pk }
+
+// Equal reports whether both public keys are the same.
+// Keys of the same length are compared in constant time, so that the
+// comparison doesn't leak how many leading bytes match.
+func (pk Pubkey) Equal(other Pubkey) bool {
+	return subtle.ConstantTimeCompare(pk, other) == 1
+}
@@ -0,0 +1,116 @@
+package wendy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// Marshal encodes a SignedVote into its wire format.
+// The encoding is as follows, where variable length fields are prefixed by
+// their length encoded as a big endian uint32:
+//
+//	scheme (byte) | pubkey | signature | label | seq (uint64) | tx_hash | time (int64 unix nano) | prev_hash
+//
+// Note that the tx itself is not part of the vote, only its hash.
+func (sv *SignedVote) Marshal() ([]byte, error) {
+	v := sv.Data
+	if v == nil {
+		return nil, fmt.Errorf("%w: signed vote has no data", ErrInvalidEncoding)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(byte(sv.Scheme))
+	for _, field := range [][]byte{v.Pubkey, sv.Signature, []byte(v.Label)} {
+		writeBytes(buf, field)
+	}
+
+	for _, i := range []interface{}{
+		v.Seq,
+		v.TxHash,
+		v.Time.UnixNano(),
+		v.PrevHash,
+	} {
+		// according to buf.Buffer docs, it will never return an error.
+		if err := binary.Write(buf, binary.BigEndian, i); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSignedVote decodes a SignedVote encoded with Marshal.
+// It returns an error if the input is truncated or has trailing bytes.
+func UnmarshalSignedVote(bz []byte) (*SignedVote, error) {
+	r := bytes.NewReader(bz)
+
+	scheme, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading scheme: %v", ErrInvalidEncoding, err)
+	}
+
+	var pub, sig, label []byte
+	for _, field := range []struct {
+		name string
+		dst  *[]byte
+	}{
+		{"pubkey", &pub}, {"signature", &sig}, {"label", &label},
+	} {
+		if *field.dst, err = readBytes(r); err != nil {
+			return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidEncoding, field.name, err)
+		}
+	}
+
+	var (
+		v        = &Vote{Pubkey: pub, Label: string(label)}
+		unixNano int64
+	)
+	for _, field := range []struct {
+		name string
+		dst  interface{}
+	}{
+		{"seq", &v.Seq}, {"tx_hash", &v.TxHash}, {"time", &unixNano}, {"prev_hash", &v.PrevHash},
+	} {
+		if err := binary.Read(r, binary.BigEndian, field.dst); err != nil {
+			return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidEncoding, field.name, err)
+		}
+	}
+	v.Time = time.Unix(0, unixNano)
+
+	if n := r.Len(); n > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, n)
+	}
+
+	return &SignedVote{Scheme: Scheme(scheme), Signature: sig, Data: v}, nil
+}
+
+// writeBytes writes bz prefixed by its length.
+func writeBytes(buf *bytes.Buffer, bz []byte) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(bz)))
+	buf.Write(l[:])
+	buf.Write(bz)
+}
+
+// readBytes reads a length prefixed byte slice written by writeBytes.
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	var l uint32
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return nil, err
+	}
+
+	if int64(l) > int64(r.Len()) {
+		return nil, fmt.Errorf("length %d exceeds the remaining %d bytes", l, r.Len())
+	}
+
+	bz := make([]byte, l)
+	if _, err := r.Read(bz); err != nil {
+		return nil, err
+	}
+	return bz, nil
+}
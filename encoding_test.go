@@ -0,0 +1,45 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedVoteMarshal(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+
+	vote := NewVote(Pubkey(pub), 1, NewSimpleTx("tx0", "h0").withLabel("label"))
+	vote.WithPrevHash(testVote0.Hash())
+	sv := NewSignedVote(priv, vote)
+
+	bz, err := sv.Marshal()
+	require.NoError(t, err)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		got, err := UnmarshalSignedVote(bz)
+		require.NoError(t, err)
+
+		assert.True(t, got.Verify())
+		assert.Equal(t, sv.Signature, got.Signature)
+		assert.Equal(t, vote.Hash(), got.Data.Hash())
+		assert.Equal(t, vote.Pubkey, got.Data.Pubkey)
+		assert.Equal(t, vote.Label, got.Data.Label)
+		assert.Equal(t, sv.GossipID(), got.GossipID())
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		for _, n := range []int{0, 3, 10, len(bz) - 1} {
+			_, err := UnmarshalSignedVote(bz[:n])
+			assert.ErrorIs(t, err, ErrInvalidEncoding, "truncated at %d", n)
+		}
+	})
+
+	t.Run("OverLong", func(t *testing.T) {
+		_, err := UnmarshalSignedVote(append(bz, 0x00))
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+	})
+}
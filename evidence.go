@@ -0,0 +1,148 @@
+package wendy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidEvidence = errors.New("invalid evidence")
+
+// Evidence is the proof that a validator equivocated, i.e it signed votes for
+// two different txs using the same sequence number, see EvidenceFor.
+// First and Second are sorted by their vote hash, so that the same
+// equivocation always produces the same evidence.
+type Evidence struct {
+	First  *SignedVote
+	Second *SignedVote
+}
+
+func newEvidence(a, b *SignedVote) *Evidence {
+	ha, hb := a.Data.Hash(), b.Data.Hash()
+	if bytes.Compare(ha[:], hb[:]) > 0 {
+		a, b = b, a
+	}
+	return &Evidence{First: a, Second: b}
+}
+
+// Verify verifies the evidence independently of any Wendy state: both
+// signatures must be valid (see SignedVote.VerifyE) and both votes must come
+// from the same validator, using the same label and sequence number, for
+// different txs.
+func (e *Evidence) Verify() error {
+	if e.First == nil || e.Second == nil || e.First.Data == nil || e.Second.Data == nil {
+		return fmt.Errorf("%w: missing votes", ErrInvalidEvidence)
+	}
+	for _, sv := range []*SignedVote{e.First, e.Second} {
+		if err := sv.VerifyE(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+		}
+	}
+
+	v1, v2 := e.First.Data, e.Second.Data
+	switch {
+	case !v1.Pubkey.Equal(v2.Pubkey):
+		return fmt.Errorf("%w: votes from different validators", ErrInvalidEvidence)
+	case v1.Label != v2.Label || v1.Seq != v2.Seq:
+		return fmt.Errorf("%w: votes with different sequence numbers", ErrInvalidEvidence)
+	case v1.TxHash == v2.TxHash:
+		return fmt.Errorf("%w: votes for the same tx", ErrInvalidEvidence)
+	}
+	return nil
+}
+
+// Marshal encodes the evidence as both signed votes in their wire format (see
+// SignedVote.Marshal), each one prefixed by its length encoded as a big
+// endian uint32.
+func (e *Evidence) Marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, sv := range []*SignedVote{e.First, e.Second} {
+		if sv == nil {
+			return nil, fmt.Errorf("%w: evidence is missing votes", ErrInvalidEncoding)
+		}
+		bz, err := sv.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		writeBytes(buf, bz)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalEvidence decodes an Evidence encoded with Marshal.
+// The evidence is not verified, see Evidence.Verify.
+func UnmarshalEvidence(bz []byte) (*Evidence, error) {
+	r := bytes.NewReader(bz)
+
+	var votes [2]*SignedVote
+	for i := range votes {
+		raw, err := readBytes(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: reading vote %d: %v", ErrInvalidEncoding, i, err)
+		}
+		if votes[i], err = UnmarshalSignedVote(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if n := r.Len(); n > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, n)
+	}
+	return &Evidence{First: votes[0], Second: votes[1]}, nil
+}
+
+// EvidenceFor returns the encoded Evidence (see Evidence.Marshal) of the
+// first equivocation of the validator for which both signed votes are known.
+// Evidence is only recorded when both conflicting votes have been added via
+// AddSignedVote and the first one was still pending when the second one was
+// added. It returns false if there is no evidence for the validator.
+func (w *Wendy) EvidenceFor(id ID) ([]byte, bool) {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	e, ok := w.evidence[id]
+	if !ok {
+		return nil, false
+	}
+	bz, err := e.Marshal()
+	if err != nil {
+		return nil, false
+	}
+	return bz, true
+}
+
+// numEquivocations returns the number of equivocations registered by the
+// peer.
+// The caller must hold the peersMtx lock.
+func (w *Wendy) numEquivocations(id ID) int {
+	peer, ok := w.peers[id]
+	if !ok {
+		return 0
+	}
+	return len(peer.Equivocations())
+}
+
+// recordEvidence records the evidence of the equivocation registered while
+// adding sv, if any, given the number of equivocations the peer had before.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) recordEvidence(sv *SignedVote, before int) {
+	id := sv.Data.Key()
+	if _, ok := w.evidence[id]; ok {
+		return
+	}
+	peer, ok := w.peers[id]
+	if !ok {
+		return
+	}
+	proofs := peer.Equivocations()
+	if len(proofs) <= before {
+		return
+	}
+
+	first := proofs[len(proofs)-1].First
+	signed, ok := w.signatures[first.TxHash][id]
+	if !ok || signed.Data.Hash() != first.Hash() {
+		return
+	}
+	w.evidence[id] = newEvidence(signed, sv)
+}
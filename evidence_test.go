@@ -0,0 +1,76 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvidenceFor(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+	id := ID(Pubkey(pub).String())
+
+	w := New()
+	w.UpdateValidatorSet([]Validator{Validator(pub)})
+
+	first := NewSignedVote(priv, NewVote(Pubkey(pub), 0, testTx0))
+	_, err = w.AddSignedVote(first)
+	require.NoError(t, err)
+	_, ok := w.EvidenceFor(id)
+	require.False(t, ok)
+
+	second := NewSignedVote(priv, NewVote(Pubkey(pub), 0, testTx1))
+	added, err := w.AddSignedVote(second)
+	require.NoError(t, err)
+	require.False(t, added)
+
+	bz, ok := w.EvidenceFor(id)
+	require.True(t, ok)
+
+	e, err := UnmarshalEvidence(bz)
+	require.NoError(t, err)
+	require.NoError(t, e.Verify())
+	assert.ElementsMatch(t,
+		[]Hash{first.Data.Hash(), second.Data.Hash()},
+		[]Hash{e.First.Data.Hash(), e.Second.Data.Hash()},
+	)
+
+	// the evidence is canonical.
+	other, err := newEvidence(second, first).Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, bz, other)
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, priv2, err := ed25519.GenerateKey(Rand)
+		require.NoError(t, err)
+
+		for name, e := range map[string]*Evidence{
+			"SameTx":  {First: first, Second: first},
+			"Missing": {First: first},
+			"OtherSeq": {First: first, Second: NewSignedVote(priv,
+				NewVote(Pubkey(pub), 1, testTx1))},
+			"BadSignature": {First: first, Second: NewSignedVote(priv2,
+				NewVote(Pubkey(pub), 0, testTx1))},
+		} {
+			assert.ErrorIs(t, e.Verify(), ErrInvalidEvidence, name)
+		}
+
+		_, err = UnmarshalEvidence(bz[:len(bz)-1])
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+	})
+
+	t.Run("UnsignedVotes", func(t *testing.T) {
+		w := New()
+		w.UpdateValidatorSet([]Validator{Validator(pub)})
+		require.NoError(t, w.AddVotes(first.Data))
+		_, err := w.AddSignedVote(second)
+		require.NoError(t, err)
+
+		require.Len(t, w.Equivocations()[id], 1)
+		_, ok := w.EvidenceFor(id)
+		assert.False(t, ok, "the first vote was not signed")
+	})
+}
@@ -0,0 +1,107 @@
+package wendy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Explanation describes the fairness state of a tx, see Explain.
+type Explanation struct {
+	TxHash Hash
+
+	// Pending is false if the tx hasn't been added or it has already been
+	// committed or removed.
+	Pending bool
+
+	// Seen is the voting weight of the peers that have seen the tx (see
+	// Peer.Seen) and Quorum the weight required for it to be unblocked.
+	Seen   uint64
+	Quorum uint64
+
+	// Blocked reports whether the tx is blocked, see IsBlocked.
+	Blocked bool
+
+	// BlockedBy holds the hashes of the pending txs that might have priority
+	// over the tx (see IsBlockedBy), sorted.
+	BlockedBy []Hash
+
+	// Cycle holds the hashes of the txs of the fairness loop the tx is part
+	// of, sorted, it is nil when the tx is not part of any loop.
+	Cycle []Hash
+}
+
+// Explain returns why tx is (or isn't) blocked, aggregating the results of
+// IsBlocked, IsBlockedBy and BlockingSet.Cycles for the tx, all of them
+// computed under the same lock.
+func (w *Wendy) Explain(tx Tx) Explanation {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	hash := tx.Hash()
+	e := Explanation{
+		TxHash:  hash,
+		Pending: w.txs.ByHash(hash) != nil,
+		Blocked: w.isBlocked(tx),
+	}
+	e.Seen, e.Quorum, _ = w.seenWeight(tx)
+
+	for _, tx2 := range w.txs.List() {
+		if tx2.Hash() != hash && w.blockedBy(tx, tx2) {
+			e.BlockedBy = append(e.BlockedBy, tx2.Hash())
+		}
+	}
+	sortHashes(e.BlockedBy)
+
+	if e.Pending {
+	loops:
+		for _, cycle := range w.blockingSet().Cycles() {
+			for _, h := range cycle {
+				if h == hash {
+					e.Cycle = cycle
+					break loops
+				}
+			}
+		}
+	}
+	return e
+}
+
+// String returns the explanation in a human readable form.
+func (e Explanation) String() string {
+	state := "is not blocked"
+	if e.Blocked {
+		state = "is blocked"
+	}
+	if !e.Pending {
+		state = "is not pending and " + state
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "tx %s %s", shortHash(e.TxHash), state)
+	fmt.Fprintf(&b, ": seen by a voting weight of %d, the quorum is %d", e.Seen, e.Quorum)
+
+	if len(e.BlockedBy) > 0 {
+		fmt.Fprintf(&b, "; %d txs might have priority over it: %s", len(e.BlockedBy), shortHashes(e.BlockedBy))
+	}
+	if len(e.Cycle) > 0 {
+		fmt.Fprintf(&b, "; part of a fairness loop: %s", shortHashes(e.Cycle))
+	}
+	return b.String()
+}
+
+// shortHash returns the first 8 bytes of the hash hex encoded.
+func shortHash(h Hash) string {
+	return hex.EncodeToString(h[:8])
+}
+
+func shortHashes(hashes []Hash) string {
+	s := make([]string, len(hashes))
+	for i, h := range hashes {
+		s[i] = shortHash(h)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,43 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+
+		e := w.Explain(testTx1)
+		assert.True(t, e.Pending)
+		assert.False(t, e.Blocked)
+		assert.Equal(t, uint64(5), e.Seen)
+		assert.Equal(t, uint64(4), e.Quorum)
+		assert.Equal(t, []Hash{testTx3.Hash(), testTx4.Hash(), testTx5.Hash()}, e.BlockedBy)
+		assert.Len(t, e.Cycle, 5)
+		assert.Contains(t, e.String(), "is not blocked: seen by a voting weight of 5, the quorum is 4")
+		assert.Contains(t, e.String(), "3 txs might have priority over it")
+		assert.Contains(t, e.String(), "part of a fairness loop")
+	})
+
+	t.Run("FullyAgree", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+
+		e := w.Explain(testTx1)
+		assert.Empty(t, e.BlockedBy)
+		assert.Nil(t, e.Cycle)
+		assert.Equal(t, []Hash{testTx1.Hash()}, w.Explain(testTx2).BlockedBy)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		w := New()
+		w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+
+		e := w.Explain(testTx0)
+		assert.False(t, e.Pending)
+		assert.True(t, e.Blocked)
+		assert.Contains(t, e.String(), "is not pending and is blocked: seen by a voting weight of 0, the quorum is 2")
+	})
+}
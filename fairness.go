@@ -0,0 +1,114 @@
+package wendy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrUnfairBlock is returned by VerifyBlockFairness when a block is not
+	// consistent with the votes.
+	ErrUnfairBlock = errors.New("unfair block")
+
+	// ErrUnknownValidator is returned by VerifyBlockFairness when a vote is
+	// not signed by any of the validators.
+	ErrUnknownValidator = errors.New("unknown validator")
+)
+
+// VerifyBlockFairness verifies that block could have been produced by Wendy
+// out of the signed votes of the validators, without a live Wendy instance,
+// e.g so that light clients can audit blocks.
+// The votes are verified (see SignedVote.VerifyE) and the blocking relations
+// are reconstructed from them, the block is fair if none of its txs is
+// blocked (see IsBlocked), every tx blocking any of its txs is part of the
+// block as well, and no tx is placed before a tx that
+// has priority over it, i.e the former is blocked by the latter but not the
+// other way around (see IsBlockedBy), which is the rule proposals are checked
+// against. Txs that block each other, e.g because they are part of a fairness
+// loop, can be in any order. Blocks must therefore be in blocking order (see
+// BlockingSet.Order) rather than the hash order of NewBlock.
+// The votes for txs committed by earlier blocks must not be part of votes,
+// otherwise the txs they vote for are expected in the block.
+// It returns an error wrapping ErrUnfairBlock describing the first violation
+// found, an error wrapping ErrUnknownValidator if a vote is not signed by one
+// of the validators, an error wrapping ErrInvalidQuorumFraction if quorumFraction is
+// outside the (0.5, 1) range, or the error of the first vote or validator set
+// that is invalid.
+func VerifyBlockFairness(block Block, votes []*SignedVote, validators []Validator, quorumFraction float64) error {
+	if err := validateQuorumFraction(quorumFraction); err != nil {
+		return err
+	}
+
+	w := New(WithQuorumFraction(quorumFraction))
+	if _, err := w.UpdateValidatorSetStrict(validators); err != nil {
+		return err
+	}
+
+	// votes of other keys would be counted as peers, see AddVote.
+	known := make(map[ID]struct{}, len(validators))
+	for _, v := range validators {
+		known[ID(Pubkey(v).String())] = struct{}{}
+	}
+	for _, sv := range votes {
+		if _, ok := known[sv.Data.Key()]; !ok {
+			return fmt.Errorf("vote %s: %w", sv.Data, ErrUnknownValidator)
+		}
+	}
+
+	// votes are added in sequence order so that they link (see
+	// Vote.PrevHash), they are copied since adding them sets ReceivedAt.
+	sorted := make([]*SignedVote, len(votes))
+	for i, sv := range votes {
+		c, v := *sv, *sv.Data
+		c.Data = &v
+		sorted[i] = &c
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, vj := sorted[i].Data, sorted[j].Data
+		if ki, kj := vi.Key(), vj.Key(); ki != kj {
+			return ki < kj
+		}
+		if vi.Label != vj.Label {
+			return vi.Label < vj.Label
+		}
+		return vi.Seq < vj.Seq
+	})
+	for _, sv := range sorted {
+		if _, err := w.AddSignedVote(sv); err != nil {
+			return fmt.Errorf("vote %s: %w", sv.Data, err)
+		}
+	}
+
+	// the txs only known by their votes are added by hash.
+	for _, tx := range block.Txs {
+		w.AddTx(tx)
+	}
+	for _, sv := range sorted {
+		w.AddTx(&decodedTx{hash: sv.Data.TxHash, label: sv.Data.Label})
+	}
+
+	set := w.BlockingSet()
+	for _, tx := range block.Txs {
+		if w.IsBlocked(tx) {
+			return fmt.Errorf("%w: tx %s is blocked",
+				ErrUnfairBlock, shortHash(tx.Hash()))
+		}
+		for _, blocker := range set[tx.Hash()] {
+			if !containsTx(block.Txs, blocker.Hash()) {
+				return fmt.Errorf("%w: tx %s is blocked by %s, which is not part of the block",
+					ErrUnfairBlock, shortHash(tx.Hash()), shortHash(blocker.Hash()))
+			}
+		}
+	}
+
+	for i, tx1 := range block.Txs {
+		for _, tx2 := range block.Txs[i+1:] {
+			if w.IsBlockedBy(tx1, tx2) && !w.IsBlockedBy(tx2, tx1) {
+				return fmt.Errorf("%w: tx %s is placed before %s, which has priority over it",
+					ErrUnfairBlock, shortHash(tx1.Hash()), shortHash(tx2.Hash()))
+			}
+		}
+	}
+	return nil
+}
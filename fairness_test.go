@@ -0,0 +1,101 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBlockFairness(t *testing.T) {
+	var (
+		validators []Validator
+		votes      []*SignedVote
+	)
+	for i := 0; i < 3; i++ {
+		pub, priv, err := ed25519.GenerateKey(Rand)
+		require.NoError(t, err)
+		validators = append(validators, Validator(pub))
+
+		// every validator sees testTx0 before testTx1, only the last one
+		// sees testTx2.
+		txs := []Tx{testTx0, testTx1}
+		if i == 2 {
+			txs = append(txs, testTx2)
+		}
+		var prev Hash
+		for seq, tx := range txs {
+			v := NewVote(Pubkey(pub), uint64(seq), tx).WithPrevHash(prev)
+			prev = v.Hash()
+			votes = append(votes, NewSignedVote(priv, v))
+		}
+	}
+
+	// votes are not expected in any specific order.
+	Rand.Shuffle(len(votes), func(i, j int) {
+		votes[i], votes[j] = votes[j], votes[i]
+	})
+
+	for _, txs := range [][]Tx{
+		{testTx0},
+		{testTx0, testTx1},
+	} {
+		err := VerifyBlockFairness(Block{Txs: txs}, votes, validators, Quorum)
+		assert.NoError(t, err, "block %v", txs)
+	}
+
+	for _, txs := range [][]Tx{
+		{testTx1},
+		{testTx0, testTx2},
+		// every validator sees testTx0 before testTx1.
+		{testTx1, testTx0},
+		// testTx3 has no votes, so it's blocked by the rest.
+		{testTx0, testTx3},
+		// testTx2 is only seen by one validator, so it's blocked.
+		{testTx0, testTx1, testTx2},
+	} {
+		err := VerifyBlockFairness(Block{Txs: txs}, votes, validators, Quorum)
+		assert.ErrorIs(t, err, ErrUnfairBlock, "block %v", txs)
+	}
+
+	t.Run("InvalidVote", func(t *testing.T) {
+		tampered := *votes[0]
+		tampered.Signature = make([]byte, ed25519.SignatureSize)
+		invalid := append([]*SignedVote{&tampered}, votes[1:]...)
+
+		err := VerifyBlockFairness(Block{Txs: []Tx{testTx0}}, invalid, validators, Quorum)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("BlockedTx", func(t *testing.T) {
+		// there are no blockers to miss, but no validator has seen testTx3.
+		err := VerifyBlockFairness(Block{Txs: []Tx{testTx3}}, nil, validators, Quorum)
+		assert.ErrorIs(t, err, ErrUnfairBlock)
+	})
+
+	t.Run("UnknownValidator", func(t *testing.T) {
+		// a quorum of keys outside of the validator set votes for testTx3.
+		var unknown []*SignedVote
+		for i := 0; i < 3; i++ {
+			pub, priv, err := ed25519.GenerateKey(Rand)
+			require.NoError(t, err)
+			unknown = append(unknown, NewSignedVote(priv, NewVote(Pubkey(pub), 0, testTx3)))
+		}
+
+		err := VerifyBlockFairness(Block{Txs: []Tx{testTx3}}, unknown, validators, Quorum)
+		assert.ErrorIs(t, err, ErrUnknownValidator)
+	})
+
+	t.Run("InvalidQuorumFraction", func(t *testing.T) {
+		for _, f := range []float64{0, 0.5, 1} {
+			err := VerifyBlockFairness(Block{Txs: []Tx{testTx0}}, votes, validators, f)
+			assert.ErrorIs(t, err, ErrInvalidQuorumFraction, "fraction %v", f)
+		}
+	})
+
+	t.Run("InvalidValidators", func(t *testing.T) {
+		err := VerifyBlockFairness(Block{}, votes, append(validators, validators[0]), Quorum)
+		assert.ErrorIs(t, err, ErrDuplicateValidator)
+	})
+}
@@ -0,0 +1,127 @@
+//go:build go1.18
+// +build go1.18
+
+package wendy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fuzzPubs = []Pubkey{
+	pub0, pub1, pub2, pub3, newRandPubkey(), newRandPubkey(), newRandPubkey(),
+}
+
+// FuzzBlockingSet feeds random validator sets and vote orderings to Wendy and
+// checks the invariants of the blocking logic.
+// The input is decoded as follows:
+//   - data[0]: the number of validators.
+//   - data[1]: the number of txs.
+//   - data[2:]: one vote per byte, the low 3 bits select the validator, the
+//     next 3 bits select the tx, bit 6 skips a sequence number (leaving a
+//     gap) and bit 7 makes the validator equivocate on its last sequence
+//     number.
+//
+// Run it with `go test -fuzz FuzzBlockingSet`.
+func FuzzBlockingSet(f *testing.F) {
+	f.Add([]byte{3, 3, 0x00, 0x01, 0x02, 0x08, 0x09, 0x0a, 0x10, 0x11, 0x12})
+	f.Add([]byte{4, 2, 0x00, 0x08, 0x09, 0x01, 0x02, 0x0a, 0x03, 0x0b})
+	f.Add([]byte{5, 5, 0x00, 0x08, 0x10, 0x01, 0x11, 0x09, 0x52, 0x8a, 0x1b})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 2 {
+			return
+		}
+		numVals := 1 + int(data[0])%len(fuzzPubs)
+		numTxs := 1 + int(data[1])%8
+
+		w := New()
+		var vs []Validator
+		for _, pub := range fuzzPubs[:numVals] {
+			vs = append(vs, pub.Bytes())
+		}
+		w.UpdateValidatorSet(vs)
+
+		txs := make([]Tx, numTxs)
+		for i := range txs {
+			txs[i] = NewSimpleTx(fmt.Sprintf("tx%d", i), fmt.Sprintf("h%d", i))
+			w.AddTx(txs[i])
+		}
+
+		// unblocked holds the pairs for which IsBlockedBy returned false.
+		unblocked := make(map[[2]int]bool)
+		prevVotes := make([]*Vote, numVals)
+		seqs := make([]uint64, numVals)
+		for _, b := range data[2:] {
+			val, tx := int(b&0x07)%numVals, int(b>>3&0x07)%numTxs
+			pub := fuzzPubs[val]
+
+			var vote *Vote
+			switch prev := prevVotes[val]; {
+			case b&0x80 != 0 && prev != nil:
+				vote = NewVote(pub, prev.Seq, txs[tx]).WithPrevHash(prev.PrevHash)
+			case b&0x40 != 0:
+				seqs[val]++
+				fallthrough
+			default:
+				vote = NewVote(pub, seqs[val], txs[tx])
+				if prev != nil && prev.Seq+1 == vote.Seq {
+					vote.WithPrevHash(prev.Hash())
+				}
+				prevVotes[val] = vote
+				seqs[val]++
+			}
+			_, err := w.AddVote(vote)
+			require.NoError(t, err)
+
+			// votes are added with increasing seqs, so IsBlockedBy must be
+			// monotone.
+			for i, tx1 := range txs {
+				for j, tx2 := range txs {
+					blocked := w.IsBlockedBy(tx1, tx2)
+					if unblocked[[2]int{i, j}] {
+						require.False(t, blocked, "IsBlockedBy(%d, %d) MUST be monotone", i, j)
+					}
+					if !blocked {
+						unblocked[[2]int{i, j}] = true
+					}
+				}
+			}
+		}
+
+		set := w.BlockingSet()
+		assert.Equal(t, set, w.BlockingSetCached())
+
+		// every tx of a loop is blocked by the rest of the txs of the loop.
+		inCycle := make(map[Hash]bool)
+		for _, cycle := range set.Cycles() {
+			for _, hash := range cycle {
+				inCycle[hash] = true
+				for _, other := range cycle {
+					assert.True(t, containsTx(set[hash], other))
+				}
+			}
+		}
+
+		known := make(map[Hash]bool)
+		for _, tx := range txs {
+			known[tx.Hash()] = true
+		}
+
+		block := w.NewBlock()
+		included := make(map[Hash]bool)
+		for _, tx := range block.Txs {
+			hash := tx.Hash()
+			require.True(t, known[hash], "unknown tx in block")
+			require.False(t, included[hash], "duplicated tx in block")
+			included[hash] = true
+		}
+		for hash := range block.Cyclic {
+			assert.True(t, included[hash], "cyclic tx not in block")
+			assert.True(t, inCycle[hash], "cyclic tx not in a loop")
+		}
+	})
+}
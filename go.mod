@@ -6,7 +6,7 @@ require (
 	github.com/golang/protobuf v1.4.3
 	github.com/prometheus/client_golang v1.8.0
 	github.com/rs/cors v1.7.0
-	github.com/sebdah/goldie/v2 v2.5.3 // indirect
+	github.com/sebdah/goldie/v2 v2.5.3
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
 	github.com/tendermint/tendermint v0.34.10-0.20210412090926-03393fb6ec80
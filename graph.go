@@ -0,0 +1,78 @@
+package wendy
+
+// Graph is the blocking relationship of a BlockingSet as a directed graph,
+// see BlockingSet.Graph.
+// There is an edge from tx2 to tx1 when tx1 is blocked by tx2, i.e edges go
+// from the txs that must go first to the txs that depend on them. Since the
+// BlockingSet holds all the txs transitively blocking a tx, so does the graph.
+type Graph struct {
+	set   BlockingSet
+	nodes []Hash
+
+	// in and out hold the incoming and outgoing edges of every node.
+	in  map[Hash][]Hash
+	out map[Hash][]Hash
+}
+
+// Graph returns the blocking relationship of the set as a Graph, which holds
+// every tx of the set, including the blocking ones.
+func (set BlockingSet) Graph() *Graph {
+	g := &Graph{
+		set: set,
+		in:  make(map[Hash][]Hash),
+		out: make(map[Hash][]Hash),
+	}
+
+	for hash := range set.txsByHash() {
+		g.nodes = append(g.nodes, hash)
+	}
+	sortHashes(g.nodes)
+
+	for _, edge := range set.Edges() {
+		dependent, dependency := edge[0], edge[1]
+		g.in[dependent] = append(g.in[dependent], dependency)
+		g.out[dependency] = append(g.out[dependency], dependent)
+	}
+	return g
+}
+
+// Nodes returns the hashes of all the txs in the graph sorted.
+func (g *Graph) Nodes() []Hash {
+	return append([]Hash(nil), g.nodes...)
+}
+
+// InDegree returns the number of txs blocking the tx.
+func (g *Graph) InDegree(hash Hash) int {
+	return len(g.in[hash])
+}
+
+// OutDegree returns the number of txs blocked by the tx.
+func (g *Graph) OutDegree(hash Hash) int {
+	return len(g.out[hash])
+}
+
+// Roots returns the hashes of the txs that are not blocked by any other tx,
+// sorted.
+// Txs that are part of a fairness loop are never roots.
+func (g *Graph) Roots() []Hash {
+	var roots []Hash
+	for _, hash := range g.nodes {
+		if g.InDegree(hash) == 0 {
+			roots = append(roots, hash)
+		}
+	}
+	return roots
+}
+
+// TopoSort returns the hashes of the txs sorted so that every tx comes after
+// the txs blocking it, following the same rules as BlockingSet.Order,
+// including ErrFairnessLoop when the graph has cycles.
+func (g *Graph) TopoSort() ([]Hash, error) {
+	order, err := g.set.Order()
+
+	hashes := make([]Hash, len(order))
+	for i, tx := range order {
+		hashes[i] = tx.Hash()
+	}
+	return hashes, err
+}
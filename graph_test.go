@@ -0,0 +1,57 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraph(t *testing.T) {
+	t.Run("FullyAgree", func(t *testing.T) {
+		g := newWendyFromTxsMap(t, fullyAgreeTxsMap).BlockingSet().Graph()
+
+		assert.Equal(t, []Hash{testTx1.Hash()}, g.Roots())
+		assert.Len(t, g.Nodes(), 5)
+
+		// the txs are transitively blocked by all the previous ones.
+		assert.Equal(t, 0, g.InDegree(testTx1.Hash()))
+		assert.Equal(t, 4, g.OutDegree(testTx1.Hash()))
+		assert.Equal(t, 4, g.InDegree(testTx5.Hash()))
+		assert.Equal(t, 0, g.OutDegree(testTx5.Hash()))
+
+		order, err := g.TopoSort()
+		assert.NoError(t, err)
+		assert.Equal(t, []Hash{
+			testTx1.Hash(), testTx2.Hash(), testTx3.Hash(), testTx4.Hash(), testTx5.Hash(),
+		}, order)
+	})
+
+	t.Run("FairnessLoop", func(t *testing.T) {
+		set := newWendyFromTxsMap(t, fairnessLoopTxsMap).BlockingSet()
+		g := set.Graph()
+
+		assert.Empty(t, g.Roots())
+		for _, hash := range g.Nodes() {
+			assert.Equal(t, 4, g.InDegree(hash))
+			assert.Equal(t, 4, g.OutDegree(hash))
+		}
+
+		order, err := g.TopoSort()
+		assert.ErrorIs(t, err, ErrFairnessLoop)
+		txs, _ := set.Order()
+		for i, tx := range txs {
+			assert.Equal(t, tx.Hash(), order[i])
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		g := BlockingSet{}.Graph()
+		assert.Empty(t, g.Nodes())
+		assert.Empty(t, g.Roots())
+		assert.Equal(t, 0, g.InDegree(testTx0.Hash()))
+
+		order, err := g.TopoSort()
+		assert.NoError(t, err)
+		assert.Empty(t, order)
+	})
+}
@@ -0,0 +1,63 @@
+package wendy
+
+// history keeps the last committed blocks, see WithHistory.
+type history struct {
+	size   int
+	blocks []Block // blocks holds the committed blocks, oldest first.
+	first  uint64  // first is the number of blocks evicted so far.
+
+	// byTx maps the hashes of the committed txs to the number of the last
+	// block that included them.
+	byTx map[Hash]uint64
+}
+
+func newHistory(size int) *history {
+	return &history{size: size, byTx: make(map[Hash]uint64)}
+}
+
+// add records a committed block, evicting the oldest one if the history is
+// full.
+func (h *history) add(block Block) {
+	// the txs are copied so that the caller can't modify the history.
+	block.Txs = append([]Tx(nil), block.Txs...)
+
+	n := h.first + uint64(len(h.blocks))
+	h.blocks = append(h.blocks, block)
+	for _, tx := range block.Txs {
+		h.byTx[tx.Hash()] = n
+	}
+
+	if len(h.blocks) > h.size {
+		for _, tx := range h.blocks[0].Txs {
+			if h.byTx[tx.Hash()] == h.first {
+				delete(h.byTx, tx.Hash())
+			}
+		}
+		h.blocks[0] = Block{}
+		h.blocks = h.blocks[1:]
+		h.first++
+	}
+}
+
+// block returns the last committed block that included the tx hash.
+func (h *history) block(hash Hash) (Block, bool) {
+	n, ok := h.byTx[hash]
+	if !ok {
+		return Block{}, false
+	}
+	return h.blocks[n-h.first], true
+}
+
+// CommittedBlock returns the last committed block (see CommitBlock) that
+// included the tx hash, it returns false if the tx hasn't been committed in
+// any of the blocks kept by the history.
+// It always returns false unless Wendy was created using WithHistory.
+func (w *Wendy) CommittedBlock(hash Hash) (Block, bool) {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	if w.history == nil {
+		return Block{}, false
+	}
+	return w.history.block(hash)
+}
@@ -0,0 +1,68 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommittedBlock(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		w := New()
+		w.AddTx(testTx0)
+		w.CommitBlock(Block{Txs: []Tx{testTx0}})
+
+		_, ok := w.CommittedBlock(testTx0.Hash())
+		assert.False(t, ok)
+	})
+
+	w := New(WithHistory(2))
+	for _, tx := range []Tx{testTx0, testTx1, testTx2, testTx3} {
+		w.AddTx(tx)
+	}
+
+	b0 := Block{Txs: []Tx{testTx0, testTx1}}
+	w.CommitBlock(b0)
+	block, ok := w.CommittedBlock(testTx1.Hash())
+	require.True(t, ok)
+	assert.Equal(t, b0, block)
+
+	_, ok = w.CommittedBlock(testTx2.Hash())
+	assert.False(t, ok, "testTx2 is still pending")
+
+	b1 := Block{Txs: []Tx{testTx2}}
+	b2 := Block{Txs: []Tx{testTx3}}
+	w.CommitBlock(b1)
+	w.CommitBlock(b2)
+
+	_, ok = w.CommittedBlock(testTx0.Hash())
+	assert.False(t, ok, "b0 has been evicted")
+
+	block, ok = w.CommittedBlock(testTx2.Hash())
+	require.True(t, ok)
+	assert.Equal(t, b1, block)
+
+	block, ok = w.CommittedBlock(testTx3.Hash())
+	require.True(t, ok)
+	assert.Equal(t, b2, block)
+
+	t.Run("Recommitted", func(t *testing.T) {
+		// a tx committed twice is reported in its last block, even after the
+		// first one is evicted.
+		b3 := Block{Txs: []Tx{testTx3, testTx4}}
+		w.CommitBlock(b3)
+		block, ok := w.CommittedBlock(testTx3.Hash())
+		require.True(t, ok)
+		assert.Equal(t, b3, block)
+
+		w.CommitBlock(Block{Txs: []Tx{testTx5}})
+		block, ok = w.CommittedBlock(testTx3.Hash())
+		require.True(t, ok)
+		assert.Equal(t, b3, block)
+	})
+
+	w.Reset()
+	_, ok = w.CommittedBlock(testTx5.Hash())
+	assert.False(t, ok)
+}
@@ -0,0 +1,98 @@
+package wendy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonBlock is the JSON representation of a Block.
+type jsonBlock struct {
+	Txs    []jsonTx `json:"txs"`
+	Cyclic []string `json:"cyclic,omitempty"`
+}
+
+// jsonTx is the JSON representation of a Tx, the hash is hex encoded and the
+// bytes are base64 encoded.
+type jsonTx struct {
+	Hash  string `json:"hash"`
+	Label string `json:"label"`
+	Bytes []byte `json:"bytes,omitempty"`
+}
+
+// MarshalJSON encodes the block as JSON, every tx is encoded by its hash (hex)
+// and its label. The tx bytes are included (base64) only if IncludeBytes is
+// set.
+func (b Block) MarshalJSON() ([]byte, error) {
+	jb := jsonBlock{Txs: make([]jsonTx, 0, len(b.Txs))}
+	for _, tx := range b.Txs {
+		hash := tx.Hash()
+		jtx := jsonTx{Hash: hex.EncodeToString(hash[:]), Label: tx.Label()}
+		if b.IncludeBytes {
+			jtx.Bytes = tx.Bytes()
+		}
+		jb.Txs = append(jb.Txs, jtx)
+	}
+
+	hashes := make([]Hash, 0, len(b.Cyclic))
+	for hash, ok := range b.Cyclic {
+		if ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	sortHashes(hashes)
+	for _, hash := range hashes {
+		jb.Cyclic = append(jb.Cyclic, hex.EncodeToString(hash[:]))
+	}
+
+	return json.Marshal(jb)
+}
+
+// UnmarshalJSON decodes a block encoded with MarshalJSON.
+// Since the original Tx implementation is unknown, txs are decoded into a
+// lightweight representation holding the hash, the label and the bytes (if
+// they were included).
+func (b *Block) UnmarshalJSON(bz []byte) error {
+	var jb jsonBlock
+	if err := json.Unmarshal(bz, &jb); err != nil {
+		return err
+	}
+
+	txs := make([]Tx, 0, len(jb.Txs))
+	for i, jtx := range jb.Txs {
+		hash, err := hashFromHex(fmt.Sprintf("txs[%d].hash", i), jtx.Hash)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, &decodedTx{bytes: jtx.Bytes, hash: hash, label: jtx.Label})
+	}
+
+	var cyclic map[Hash]bool
+	for i, s := range jb.Cyclic {
+		hash, err := hashFromHex(fmt.Sprintf("cyclic[%d]", i), s)
+		if err != nil {
+			return err
+		}
+		if cyclic == nil {
+			cyclic = make(map[Hash]bool)
+		}
+		cyclic[hash] = true
+	}
+
+	b.Txs, b.Cyclic = txs, cyclic
+	return nil
+}
+
+// hashFromHex turns a hex encoded string into a Hash.
+func hashFromHex(field, s string) (Hash, error) {
+	bz, err := hex.DecodeString(s)
+	if err != nil {
+		return Hash{}, fmt.Errorf("%w: %s: %v", ErrInvalidEncoding, field, err)
+	}
+
+	hash, err := HashFromBytes(bz)
+	if err != nil {
+		return hash, fmt.Errorf("%w: %s: %v", ErrInvalidEncoding, field, err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,56 @@
+package wendy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockJSON(t *testing.T) {
+	txs := []Tx{testTx0, testTx1, testTx2}
+	block := Block{
+		Txs:    txs,
+		Cyclic: map[Hash]bool{testTx1.Hash(): true, testTx2.Hash(): true},
+	}
+
+	t.Run("WithoutBytes", func(t *testing.T) {
+		bz, err := json.Marshal(block)
+		require.NoError(t, err)
+		assert.NotContains(t, string(bz), `"bytes"`)
+
+		var decoded Block
+		require.NoError(t, json.Unmarshal(bz, &decoded))
+		assert.True(t, block.Equal(decoded))
+		assert.Equal(t, block.Cyclic, decoded.Cyclic)
+		for i, tx := range decoded.Txs {
+			assert.Equal(t, txs[i].Label(), tx.Label())
+			assert.Empty(t, tx.Bytes())
+		}
+	})
+
+	t.Run("WithBytes", func(t *testing.T) {
+		block := block
+		block.IncludeBytes = true
+
+		bz, err := json.Marshal(block)
+		require.NoError(t, err)
+
+		var decoded Block
+		require.NoError(t, json.Unmarshal(bz, &decoded))
+		assert.True(t, block.Equal(decoded))
+		for i, tx := range decoded.Txs {
+			assert.Equal(t, txs[i].Bytes(), tx.Bytes())
+		}
+	})
+
+	t.Run("InvalidHash", func(t *testing.T) {
+		var decoded Block
+		err := json.Unmarshal([]byte(`{"txs":[{"hash":"abcd"}]}`), &decoded)
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+		err = json.Unmarshal([]byte(`{"txs":[{"hash":"not hex"}]}`), &decoded)
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+	})
+}
@@ -0,0 +1,144 @@
+package wendy
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrValidatorSetMismatch is returned by Merge when both instances don't share
+// the same validator set.
+var ErrValidatorSetMismatch = errors.New("validator sets don't match")
+
+// mergeState is the part of Wendy's state that is imported by Merge.
+type mergeState struct {
+	weights   map[ID]uint64
+	txs       []Tx
+	deadlines map[Hash]time.Time
+	deps      map[Hash][]Hash
+	rounds    map[Hash]int64
+	votes     [][]*Vote // votes holds the votes of every peer sorted by seq.
+}
+
+// mergeState returns a copy of the state to be merged into another instance.
+func (w *Wendy) mergeState() mergeState {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	state := mergeState{
+		weights:   w.copyWeights(),
+		txs:       append([]Tx(nil), w.txs.List()...),
+		deadlines: make(map[Hash]time.Time, len(w.deadlines)),
+		deps:      make(map[Hash][]Hash, len(w.deps)),
+		rounds:    make(map[Hash]int64, len(w.rounds)),
+	}
+	for hash, deadline := range w.deadlines {
+		state.deadlines[hash] = deadline
+	}
+	for hash, after := range w.deps {
+		state.deps[hash] = after
+	}
+	for hash, round := range w.rounds {
+		state.rounds[hash] = round
+	}
+	for _, peer := range w.peers {
+		state.votes = append(state.votes, peer.Votes())
+	}
+	return state
+}
+
+// Merge imports the state of other, e.g the state restored from a peer's
+// snapshot, into w, which keeps its own state.
+// Both instances must have the same validator set, including the weights,
+// otherwise ErrValidatorSetMismatch is returned and w is not modified.
+// The state is merged as follows:
+//   - Txs are united, the txs already known by w keep their deadline (see
+//     AddTxWithDeadline), dependencies (see AddTxWithDeps) and round (see
+//     AddTxWithRound). Txs beyond the maximum number of pending txs (see
+//     WithMaxPendingTxs) are dropped.
+//   - The votes of every peer are added as AddVote does, in sequence order.
+//     When a peer voted for the same tx with different sequence numbers,
+//     the earliest sequence is the one taken into account, as it happens for
+//     a single instance.
+//   - When both instances hold votes from a peer for different txs with the
+//     same sequence number, the vote from w is kept and the equivocation is
+//     registered (see Equivocations). Votes whose hashes don't link with the
+//     ones from w are rejected, as AddVote does.
+//
+// Signed votes (see Certificate), the committed txs and the history of
+// other are not merged.
+// The OnUnblock callbacks are invoked for the txs unblocked by the merge, and
+// the changes of the BlockingSet are published to the
+// SubscribeBlockingChanges subscribers.
+func (w *Wendy) Merge(other *Wendy) error {
+	if other == w {
+		return nil
+	}
+	state := other.mergeState()
+
+	var err error
+	callbacks, unblocked := func() ([]func(Tx), []Tx) {
+		w.txsMtx.Lock()
+		defer w.txsMtx.Unlock()
+
+		w.peersMtx.Lock()
+		defer w.peersMtx.Unlock()
+
+		if !sameWeights(w.weights, state.weights) {
+			err = ErrValidatorSetMismatch
+			return nil, nil
+		}
+
+		return w.changedBy(func() {
+			w.mergeTxs(state)
+			for _, votes := range state.votes {
+				for _, v := range votes {
+					// votes that don't link are rejected, as AddVote does.
+					_, _ = w.addVote(v)
+				}
+			}
+		})
+	}()
+	if err != nil {
+		return err
+	}
+
+	notifyUnblocked(callbacks, unblocked)
+	return nil
+}
+
+// mergeTxs adds the txs from state that are not known yet, as long as the
+// maximum number of pending txs is not reached.
+// The caller must hold the txsMtx write lock.
+func (w *Wendy) mergeTxs(state mergeState) {
+	for _, tx := range state.txs {
+		if err := w.addTx(tx); err != nil {
+			continue
+		}
+
+		hash := tx.Hash()
+		if deadline, ok := state.deadlines[hash]; ok {
+			w.deadlines[hash] = deadline
+		}
+		if after, ok := state.deps[hash]; ok {
+			w.deps[hash] = append([]Hash(nil), after...)
+		}
+		if round, ok := state.rounds[hash]; ok {
+			w.rounds[hash] = round
+		}
+	}
+}
+
+func sameWeights(a, b map[ID]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, weight := range a {
+		if w, ok := b[id]; !ok || w != weight {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package wendy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	vs := []Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes()}
+
+	local := New()
+	local.UpdateValidatorSet(vs)
+	remote := New()
+	remote.UpdateValidatorSet(vs)
+
+	// local has seen pub0 and pub1 voting testTx0 then testTx1, remote has
+	// seen pub2 voting the same and pub3 voting testTx1 first.
+	vote := func(w *Wendy, pub Pubkey, txs ...Tx) {
+		var prev *Vote
+		for i, tx := range txs {
+			v := NewVote(pub, uint64(i), tx)
+			if prev != nil {
+				v.WithPrevHash(prev.Hash())
+			}
+			prev = v
+			_, err := w.AddVote(v)
+			require.NoError(t, err)
+		}
+	}
+	local.AddTx(testTx0)
+	vote(local, pub0, testTx0, testTx1)
+	vote(local, pub1, testTx0, testTx1)
+
+	deadline := time.Now()
+	remote.AddTx(testTx0)
+	remote.AddTxWithDeadline(testTx1, deadline)
+	vote(remote, pub2, testTx0, testTx1)
+	vote(remote, pub3, testTx1, testTx2)
+	// pub0 equivocates on seq 0.
+	_, err := remote.AddVote(NewVote(pub0, 0, testTx2))
+	require.NoError(t, err)
+
+	require.True(t, local.IsBlocked(testTx0))
+	var unblocked []Tx
+	local.OnUnblock(func(tx Tx) { unblocked = append(unblocked, tx) })
+
+	changes, unsubscribe := local.SubscribeBlockingChanges()
+	defer unsubscribe()
+
+	require.NoError(t, local.Merge(remote))
+	assert.Equal(t, []Tx{testTx0}, unblocked)
+	select {
+	case delta := <-changes:
+		// testTx1 is new to local, it's blocked by testTx0.
+		assert.Equal(t, map[Hash][]Hash{testTx1.Hash(): {testTx0.Hash()}}, delta.Gained)
+		assert.Empty(t, delta.Lost)
+	default:
+		t.Fatal("expected a delta")
+	}
+	assert.Equal(t, []Tx{testTx0, testTx1}, local.txs.List())
+	assert.Equal(t, deadline, local.deadlines[testTx1.Hash()])
+	assert.False(t, local.IsBlocked(testTx0))
+	assert.True(t, local.IsBlockedBy(testTx1, testTx0), "3 of 4 validators voted testTx0 first")
+
+	// the local vote is kept on conflicts.
+	seq, ok := local.peers[ID(pub0.String())].Position(testTx0)
+	require.True(t, ok)
+	assert.Equal(t, uint64(0), seq)
+	assert.Len(t, local.Equivocations()[ID(pub0.String())], 1)
+
+	// remote is not modified.
+	assert.Equal(t, []Tx{testTx0, testTx1}, remote.txs.List())
+	assert.True(t, remote.IsBlocked(testTx0))
+
+	t.Run("ValidatorSetMismatch", func(t *testing.T) {
+		other := New()
+		other.UpdateValidatorSetWeighted(map[ID]uint64{
+			ID(pub0.String()): 2,
+			ID(pub1.String()): 1,
+			ID(pub2.String()): 1,
+			ID(pub3.String()): 1,
+		})
+		other.AddTx(testTx5)
+
+		assert.ErrorIs(t, local.Merge(other), ErrValidatorSetMismatch)
+		assert.Equal(t, []Tx{testTx0, testTx1}, local.txs.List())
+	})
+
+	t.Run("Self", func(t *testing.T) {
+		assert.NoError(t, local.Merge(local))
+	})
+}
@@ -0,0 +1,107 @@
+package wendy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "wendy"
+
+// metrics holds the prometheus metrics exposed by Wendy.
+// A nil *metrics is valid and does nothing, so that metrics are optional.
+type metrics struct {
+	votes  prometheus.Counter
+	txs    prometheus.Counter
+	peers  prometheus.Gauge
+	quorum prometheus.Gauge
+}
+
+func newMetrics(w *Wendy) (*metrics, []prometheus.Collector) {
+	m := &metrics{
+		votes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "votes_total",
+			Help:      "Number of votes added.",
+		}),
+		txs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "txs_total",
+			Help:      "Number of txs added.",
+		}),
+		peers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "peers",
+			Help:      "Number of peers.",
+		}),
+		quorum: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "quorum",
+			Help:      "Voting weight required to reach quorum.",
+		}),
+	}
+
+	// computing the number of blocked txs is expensive, so it is done only
+	// when metrics are collected.
+	blocked := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "blocked_txs",
+		Help:      "Number of blocked txs.",
+	}, func() float64 {
+		return float64(len(w.BlockedTxs()))
+	})
+
+	stalled := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "stalled",
+		Help:      "Whether Wendy is stalled (1) or not (0), see IsStalled.",
+	}, func() float64 {
+		if w.IsStalled() {
+			return 1
+		}
+		return 0
+	})
+
+	return m, []prometheus.Collector{m.votes, m.txs, m.peers, m.quorum, blocked, stalled}
+}
+
+func (m *metrics) voteAdded() {
+	if m == nil {
+		return
+	}
+	m.votes.Inc()
+}
+
+func (m *metrics) txAdded() {
+	if m == nil {
+		return
+	}
+	m.txs.Inc()
+}
+
+func (m *metrics) setPeers(n int) {
+	if m == nil {
+		return
+	}
+	m.peers.Set(float64(n))
+}
+
+func (m *metrics) setQuorum(q uint64) {
+	if m == nil {
+		return
+	}
+	m.quorum.Set(float64(q))
+}
+
+// WithMetrics registers Wendy's metrics on the given registry.
+// It panics if the metrics are already registered.
+func (w *Wendy) WithMetrics(registry *prometheus.Registry) *Wendy {
+	m, collectors := newMetrics(w)
+	registry.MustRegister(collectors...)
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.metrics = m
+	w.metrics.setPeers(len(w.peers))
+	w.metrics.setQuorum(w.quorum)
+	return w
+}
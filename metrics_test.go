@@ -0,0 +1,53 @@
+package wendy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	w := New().WithMetrics(registry)
+
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	})
+	w.AddTx(testTx0)
+	w.AddTx(testTx1)
+	w.AddTx(testTx1)
+	require.NoError(t, w.AddVotes(
+		NewVote(pub0, 0, testTx0),
+		NewVote(pub1, 0, testTx0),
+		NewVote(pub2, 0, testTx0),
+		NewVote(pub2, 0, testTx0),
+	))
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(w.metrics.votes))
+	assert.Equal(t, float64(2), testutil.ToFloat64(w.metrics.txs))
+	assert.Equal(t, float64(4), testutil.ToFloat64(w.metrics.peers))
+	assert.Equal(t, float64(3), testutil.ToFloat64(w.metrics.quorum))
+
+	// only testTx1 is blocked
+	expected := `
+# HELP wendy_blocked_txs Number of blocked txs.
+# TYPE wendy_blocked_txs gauge
+wendy_blocked_txs 1
+`
+	assert.NoError(t,
+		testutil.GatherAndCompare(registry, strings.NewReader(expected), "wendy_blocked_txs"),
+	)
+
+	expected = `
+# HELP wendy_stalled Whether Wendy is stalled (1) or not (0), see IsStalled.
+# TYPE wendy_stalled gauge
+wendy_stalled 0
+`
+	assert.NoError(t,
+		testutil.GatherAndCompare(registry, strings.NewReader(expected), "wendy_stalled"),
+	)
+}
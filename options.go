@@ -0,0 +1,165 @@
+package wendy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultStallTimeout is the default stall timeout, see WithStallTimeout.
+const DefaultStallTimeout = time.Minute
+
+// ErrInvalidQuorumFraction is returned when a quorum fraction is outside the
+// (0.5, 1) range, see WithQuorumFraction.
+var ErrInvalidQuorumFraction = errors.New("invalid quorum fraction")
+
+// Option configures a Wendy instance, see New.
+type Option func(*Wendy)
+
+// WithQuorumFraction sets the ratio of necessary votes to consider something
+// valid, which defaults to the package level Quorum.
+// The fraction must be in the (0.5, 1) range, otherwise it panics, since the
+// protocol is not safe below 1/2.
+func WithQuorumFraction(f float64) Option {
+	if err := validateQuorumFraction(f); err != nil {
+		panic(err)
+	}
+
+	return func(w *Wendy) {
+		w.quorumFraction = f
+	}
+}
+
+// validateQuorumFraction returns an error wrapping ErrInvalidQuorumFraction if
+// f is outside the (0.5, 1) range.
+func validateQuorumFraction(f float64) error {
+	if f <= 0.5 || f >= 1 {
+		return fmt.Errorf("%w: must be in the (0.5, 1) range, got %v", ErrInvalidQuorumFraction, f)
+	}
+	return nil
+}
+
+// WithRand sets the source of randomness used by the Random tie-break (see
+// NewBlockOptions.TieBreak), which defaults to crypto/rand, so that a seeded
+// reader can be used to make the blocks reproducible, e.g under test or
+// across nodes sharing the seed.
+// Blocks can be built concurrently, hence the reader must be safe for
+// concurrent use.
+func WithRand(r io.Reader) Option {
+	return func(w *Wendy) {
+		w.rand = r
+	}
+}
+
+// WithSequenceWindow limits how far ahead of a peer's highest sequence number
+// the votes can be, votes beyond the window are rejected. This prevents
+// adversarial sequence numbers from creating huge gaps.
+// A window of 0, the default, disables the limit.
+func WithSequenceWindow(n uint64) Option {
+	return func(w *Wendy) {
+		w.seqWindow = n
+	}
+}
+
+// WithGracefulTransition keeps the previous validator set around when the
+// validator set changes, so that the txs that were already pending don't
+// change their blocking state because of the change.
+// When the validator set is updated, the txs that have been voted by at least
+// one peer become part of the transition: IsBlocked, IsBlockedBy, Priority
+// and CanCommit evaluate them against the previous peers (including the
+// removed ones), weights and quorum, while any other tx, or any pair of txs
+// including one, uses the new validator set.
+// The transition lasts until all of its txs are committed or removed, or
+// until the next validator set update, which starts a new one.
+// Only the votes received before the update are retained for the removed
+// peers. Transitions are not part of the snapshot.
+func WithGracefulTransition() Option {
+	return func(w *Wendy) {
+		w.graceful = true
+	}
+}
+
+// WithHistory keeps the last n committed blocks, so that CommittedBlock can
+// find the block that included a tx after it has been pruned by CommitBlock.
+// Memory is bounded by n, older blocks are dropped. The history is local and
+// it's not part of the snapshot.
+// A history of 0, the default, disables it.
+func WithHistory(n int) Option {
+	return func(w *Wendy) {
+		w.history = nil
+		if n > 0 {
+			w.history = newHistory(n)
+		}
+	}
+}
+
+// WithMaxPendingTxs limits the number of txs held by Wendy, txs added beyond
+// the limit are rejected (see AddTxE) until some are committed or removed, so
+// that callers can apply backpressure instead of growing without bounds.
+// A limit of 0, the default, disables it.
+func WithMaxPendingTxs(n int) Option {
+	return func(w *Wendy) {
+		w.maxPendingTxs = n
+	}
+}
+
+// WithVoteCache keeps the last n signed votes added via AddSignedVote, so that
+// the signed votes received again, e.g while gossiping, are discarded without
+// verifying their signature.
+// A size of 0, the default, disables the cache.
+func WithVoteCache(n int) Option {
+	return func(w *Wendy) {
+		w.voteCache = nil
+		if n > 0 {
+			w.voteCache = newVoteCache(n)
+		}
+	}
+}
+
+// WithStallTimeout sets for how long Wendy can go without new votes, while all
+// of its pending txs are blocked, before IsStalled reports it as stalled.
+// It defaults to DefaultStallTimeout.
+func WithStallTimeout(d time.Duration) Option {
+	return func(w *Wendy) {
+		w.stallTimeout = d
+	}
+}
+
+// WithMinValidators sets the minimum number of validators required to order
+// txs safely, e.g 4 to tolerate a byzantine validator.
+// With fewer validators, UpdateValidatorSetStrict returns ErrTooFewValidators
+// while the rest of the updates halt Wendy (see IsHalted) until the
+// validator set recovers, so that fairness doesn't degrade silently.
+// A minimum of 0, the default, disables it.
+func WithMinValidators(n int) Option {
+	return func(w *Wendy) {
+		w.minValidators = n
+	}
+}
+
+// WithAdmissionPolicy sets a function that is called before adding a tx (see
+// AddTx), the tx is rejected if it returns an error, which is returned by
+// AddTxE, e.g to enforce a maximum size or a rate limit per label.
+// Txs that were already added are rejected before calling the policy.
+// The policy is called while holding Wendy's locks, therefore it must not
+// call Wendy methods, otherwise it will deadlock.
+// By default every tx is admitted.
+func WithAdmissionPolicy(fn func(Tx) error) Option {
+	return func(w *Wendy) {
+		w.admissionPolicy = fn
+	}
+}
+
+// WithFairnessTimeout relaxes fairness in favour of liveness: txs that have
+// been unblocked (see IsBlocked) for longer than d, e.g because they are
+// stuck behind a fairness loop that doesn't fit in a block, are selected
+// first for the next block without their blocking txs, see Block.Forced.
+// Txs are tracked from the moment they are added or the first batch of votes
+// after which they are unblocked.
+// A timeout of 0, the default, keeps strict fairness.
+func WithFairnessTimeout(d time.Duration) Option {
+	return func(w *Wendy) {
+		w.fairnessTimeout = d
+	}
+}
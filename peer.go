@@ -2,6 +2,9 @@ package wendy
 
 import (
 	"errors"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/vegaprotocol/wendy/utils/list"
 )
@@ -14,6 +17,24 @@ type peerBucket struct {
 	votes          *list.List
 	lastSeqSeen    uint64
 	commitedHashes map[Hash]struct{}
+
+	// byHash indexes the votes list by tx hash, so that the vote for a given
+	// tx (and thus its seq) can be found without traversing the list.
+	// If a tx was voted more than once, the vote with the lowest seq is
+	// indexed.
+	byHash map[Hash]*list.Element
+}
+
+// maxSeq returns the highest sequence number received, or lastSeqSeen if
+// greater, since votes are pruned once commited.
+func (b *peerBucket) maxSeq() uint64 {
+	max := b.lastSeqSeen
+	if last := b.votes.Back(); last != nil {
+		if seq := last.Value.(*Vote).Seq; seq > max {
+			max = seq
+		}
+	}
+	return max
 }
 
 // newPeerBucket returns an initialized peerBucket.
@@ -21,30 +42,144 @@ func newPeerBucket() *peerBucket {
 	return &peerBucket{
 		votes:          list.New(),
 		commitedHashes: make(map[Hash]struct{}),
+		byHash:         make(map[Hash]*list.Element),
+	}
+}
+
+// index registers the vote held by e in byHash.
+func (b *peerBucket) index(e *list.Element) {
+	v := e.Value.(*Vote)
+	if prev, ok := b.byHash[v.TxHash]; ok && prev.Value.(*Vote).Seq < v.Seq {
+		return
+	}
+	b.byHash[v.TxHash] = e
+}
+
+// vote returns the vote with the lowest seq for the tx with the given hash,
+// or nil if the tx hasn't been voted.
+func (b *peerBucket) vote(hash Hash) *Vote {
+	if e, ok := b.byHash[hash]; ok {
+		return e.Value.(*Vote)
+	}
+	return nil
+}
+
+// discard removes the vote with the lowest seq for the tx with the given
+// hash. It returns true if the vote was found and removed.
+func (b *peerBucket) discard(hash Hash) bool {
+	e, ok := b.byHash[hash]
+	if !ok {
+		return false
+	}
+	delete(b.byHash, hash)
+
+	// votes for the same tx with higher seqs (if any) come after e.
+	for next := e.Next(); next != nil; next = next.Next() {
+		if next.Value.(*Vote).TxHash == hash {
+			b.byHash[hash] = next
+			break
+		}
 	}
+	b.votes.Remove(e)
+	return true
 }
 
 // Peer represents a node in the network and keeps track of the votes Wendy
 // emits.
 // The Peer stores one state per label in a peerBucket.
-// Peers are not safe for concurrent access.
+// Peers are safe for concurrent access.
 // NOTE: Since the Peer never cleans up it's internal state, it always grow,
 // hence, we might need to add a persistent storage.
 type Peer struct {
-	pub     Pubkey
-	buckets map[string]*peerBucket
+	pub Pubkey
+
+	mtx           sync.RWMutex
+	weight        uint64
+	seqWindow     uint64    // seqWindow limits how far ahead a vote's seq can be, 0 means no limit.
+	lastSeen      time.Time // lastSeen is the last time a vote was added or duplicated.
+	buckets       map[string]*peerBucket
+	equivocations []EquivocationProof
+}
+
+// EquivocationProof holds two conflicting votes casted by the same peer for
+// the same sequence number.
+type EquivocationProof struct {
+	First  *Vote
+	Second *Vote
 }
 
 // NewPeer returnsa new Peer instance.
+// Peers are created with a voting weight of 1.
 func NewPeer(pub Pubkey) *Peer {
 	return &Peer{
 		pub:     pub,
+		weight:  1,
 		buckets: make(map[string]*peerBucket),
 	}
 }
 
+// Weight returns the voting weight (i.e the stake) of the peer.
+func (p *Peer) Weight() uint64 {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.weight
+}
+
+func (p *Peer) setWeight(weight uint64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.weight = weight
+}
+
+// snapshot returns the serializable state of the peer.
+func (p *Peer) snapshot() snapshotPeer {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	snap := snapshotPeer{
+		Pubkey:  p.pub,
+		Weight:  p.weight,
+		Buckets: make(map[string]snapshotBucket),
+	}
+
+	for label, b := range p.buckets {
+		bucket := snapshotBucket{LastSeqSeen: b.lastSeqSeen}
+		b.votes.Each(func(e *list.Element) bool {
+			bucket.Votes = append(bucket.Votes, e.Value.(*Vote))
+			return true
+		})
+		for hash := range b.commitedHashes {
+			bucket.CommitedHashes = append(bucket.CommitedHashes, hash)
+		}
+		snap.Buckets[label] = bucket
+	}
+	return snap
+}
+
+// newPeerFromSnapshot returns a new Peer given its serializable state.
+func newPeerFromSnapshot(snap snapshotPeer) *Peer {
+	p := NewPeer(snap.Pubkey)
+	p.weight = snap.Weight
+
+	for label, bucket := range snap.Buckets {
+		b := p.bucket(label)
+		b.lastSeqSeen = bucket.LastSeqSeen
+		// votes are stored in order, so they can be pushed back.
+		for _, vote := range bucket.Votes {
+			b.index(b.votes.PushBack(vote))
+		}
+		for _, hash := range bucket.CommitedHashes {
+			b.commitedHashes[hash] = struct{}{}
+		}
+	}
+	return p
+}
+
 // bucket returns the peerBucket corresponding to a given label.
 // A new peerBucket is created if it does not exist.
+// The caller must hold the write lock.
 func (p *Peer) bucket(label string) *peerBucket {
 	b, ok := p.buckets[label]
 	if !ok {
@@ -54,14 +189,119 @@ func (p *Peer) bucket(label string) *peerBucket {
 	return b
 }
 
+// emptyBucket is returned by readBucket for labels without a bucket.
+// It must never be modified.
+var emptyBucket = newPeerBucket()
+
+// readBucket is the read only version of bucket, it does not create a
+// peerBucket if it does not exist, hence it can be used when holding the
+// read lock.
+func (p *Peer) readBucket(label string) *peerBucket {
+	if b, ok := p.buckets[label]; ok {
+		return b
+	}
+	return emptyBucket
+}
+
+// SetSequenceWindow limits how far ahead of the highest sequence number
+// received a vote's sequence number can be, votes beyond the window are
+// rejected by AddVote. A window of 0 disables the limit.
+func (p *Peer) SetSequenceWindow(n uint64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.seqWindow = n
+}
+
+// LastSeen returns the last time AddVote was called with a vote that was
+// either added or a duplicate, it is zero if the peer has never voted.
+// Rejected votes don't count, so that a peer only sending rejected votes
+// still becomes stale, see Wendy.ExpireStalePeers.
+func (p *Peer) LastSeen() time.Time {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.lastSeen
+}
+
 // LastSeqSeen returns the last higher consecutive Seq number registered by a vote.
-func (p *Peer) LastSeqSeen(label string) uint64 { return p.bucket(label).lastSeqSeen }
+func (p *Peer) LastSeqSeen(label string) uint64 {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.readBucket(label).lastSeqSeen
+}
+
+// Gaps returns the missing sequence numbers for a given label, that is, the
+// sequence numbers between the last consecutive sequence number
+// (LastSeqSeen) and the highest sequence number received.
+// Sequence numbers up to LastSeqSeen are considered received, even if the
+// votes were pruned after being commited.
+func (p *Peer) Gaps(label string) []uint64 {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	bucket := p.readBucket(label)
+	last := bucket.votes.Back()
+	if last == nil {
+		return nil
+	}
+	max := last.Value.(*Vote).Seq
+
+	present := make(map[uint64]struct{})
+	bucket.votes.Each(func(e *list.Element) bool {
+		seq := e.Value.(*Vote).Seq
+		if seq <= bucket.lastSeqSeen {
+			return false
+		}
+		present[seq] = struct{}{}
+		return true
+	}, list.Backward)
+
+	var gaps []uint64
+	for seq := bucket.lastSeqSeen + 1; seq < max; seq++ {
+		if _, ok := present[seq]; !ok {
+			gaps = append(gaps, seq)
+		}
+	}
+	return gaps
+}
 
 // AddVote adds a vote to the vote list.
 // It returns true if the vote hasn't been added before, otherwise, the vote is
 // not added and false is returned.
+// Votes whose sequence number is beyond the sequence window (see
+// SetSequenceWindow) are not added either.
 func (p *Peer) AddVote(v *Vote) (bool, error) {
-	bucket := p.bucket(v.Label)
+	res, err := p.addVote(v)
+	return res == voteAdded, err
+}
+
+// voteResult is the outcome of adding a vote to a peer.
+type voteResult int
+
+const (
+	// voteRejected means the vote is either beyond the sequence window,
+	// equivocating or not linked to the peer's votes.
+	voteRejected voteResult = iota
+	voteAdded
+	// voteDuplicate means a vote with the same sequence number and tx was
+	// already added.
+	voteDuplicate
+)
+
+// addVote is like AddVote, but it reports why the vote was not added.
+func (p *Peer) addVote(v *Vote) (voteResult, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	// rejected votes must not create a bucket, it's created right before
+	// adding the first vote of the label.
+	bucket := p.readBucket(v.Label)
+
+	if p.seqWindow > 0 && v.Seq > bucket.maxSeq()+p.seqWindow {
+		return voteRejected, nil
+	}
 
 	// Since is most likely that votes are inserted in order (lower to higher
 	// seq numbers), we lookup the previous vote traversing the
@@ -77,29 +317,38 @@ func (p *Peer) AddVote(v *Vote) (bool, error) {
 
 		// duplicated seq number
 		if prev.Seq == v.Seq {
-			return false, nil
+			// same seq number but different tx, the peer is equivocating.
+			if prev.TxHash != v.TxHash {
+				p.addEquivocation(prev, v)
+				return voteRejected, nil
+			}
+			p.lastSeen = time.Now()
+			return voteDuplicate, nil
 		}
 
 		// Validate hash linking
 		// We need to perform 2 validations:
 		// 1. added vote against its previous one: (prev.Hash() == addedVote.PrevHash)
 		if err := validHashes(prev, v); err != nil {
-			return false, err
+			return voteRejected, err
 		}
 
 		item = bucket.votes.InsertAfter(v, item)
+		bucket.index(item)
 
 		// 2. added vote against its next one:     (addedVote.Hash() == next.PrevHash)
 		if next := item.Next(); next != nil {
 			if err := validHashes(v, next.Value.(*Vote)); err != nil {
-				return false, err
+				return voteRejected, err
 			}
 		}
 
 	} else {
-		// no votes with Sequence number.
+		// no votes with lower Sequence number.
 		// send it to the beginning of the list.
-		item = bucket.votes.PushBack(v)
+		bucket = p.bucket(v.Label)
+		item = bucket.votes.PushFront(v)
+		bucket.index(item)
 	}
 
 	// update lastSeqSeen to the higher number before a gap is found.
@@ -109,7 +358,32 @@ func (p *Peer) AddVote(v *Vote) (bool, error) {
 		}
 	}
 
-	return true, nil
+	p.lastSeen = time.Now()
+	return voteAdded, nil
+}
+
+// addEquivocation registers an equivocation unless it was already
+// registered.
+// The caller must hold the write lock.
+func (p *Peer) addEquivocation(first, second *Vote) {
+	for _, e := range p.equivocations {
+		if e.First.Hash() == first.Hash() && e.Second.Hash() == second.Hash() {
+			return
+		}
+	}
+	p.equivocations = append(p.equivocations, EquivocationProof{
+		First: first, Second: second,
+	})
+}
+
+// Equivocations returns the list of equivocations produced by the peer.
+func (p *Peer) Equivocations() []EquivocationProof {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	list := make([]EquivocationProof, len(p.equivocations))
+	copy(list, p.equivocations)
+	return list
 }
 
 func validHashes(prev, next *Vote) error {
@@ -136,10 +410,101 @@ func (p *Peer) AddVotes(vs ...*Vote) error {
 	return nil
 }
 
-func elementByHash(hash Hash) list.FilterFunc {
-	return func(e *list.Element) bool {
-		return e.Value.(*Vote).TxHash == hash
+// RemoveVote removes the vote for the tx with the given hash from every
+// bucket.
+// It returns true if the vote was found and removed.
+func (p *Peer) RemoveVote(hash Hash) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var removed bool
+	for _, bucket := range p.buckets {
+		if bucket.discard(hash) {
+			removed = true
+		}
+	}
+	return removed
+}
+
+// compact drops the votes for committed txs (see UpdateTxSet) for which
+// pending returns false, e.g votes received after the tx was committed.
+// It returns the number of votes dropped.
+func (p *Peer) compact(pending func(Hash) bool) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var n int
+	for _, bucket := range p.buckets {
+		for hash := range bucket.commitedHashes {
+			if pending(hash) {
+				continue
+			}
+			for bucket.discard(hash) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Votes returns a copy of all the votes of the peer ordered by sequence
+// number.
+func (p *Peer) Votes() []*Vote {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	var votes []*Vote
+	for _, bucket := range p.buckets {
+		bucket.votes.Each(func(e *list.Element) bool {
+			v := *e.Value.(*Vote)
+			votes = append(votes, &v)
+			return true
+		})
+	}
+
+	sort.SliceStable(votes, func(i, j int) bool {
+		return votes[i].Seq < votes[j].Seq
+	})
+	return votes
+}
+
+// ReplayOrdered calls fn with a copy of every vote of the peer by ascending
+// sequence number, e.g to validate the history of a restored peer, and stops
+// at the first error, which is returned.
+// Since sequence numbers are kept per label, the votes of every label are
+// replayed in turn, in label order.
+// Gaps in the sequence numbers are passed through as they are, it's up to fn
+// to decide whether they are acceptable, see Gaps.
+// fn is called without holding the peer's lock, so the votes added meanwhile
+// are not replayed.
+func (p *Peer) ReplayOrdered(fn func(seq uint64, v *Vote) error) error {
+	var votes []*Vote
+	func() {
+		p.mtx.RLock()
+		defer p.mtx.RUnlock()
+
+		labels := make([]string, 0, len(p.buckets))
+		for label := range p.buckets {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		for _, label := range labels {
+			// the votes of a bucket are sorted by sequence number.
+			p.buckets[label].votes.Each(func(e *list.Element) bool {
+				v := *e.Value.(*Vote)
+				votes = append(votes, &v)
+				return true
+			})
+		}
+	}()
+
+	for _, v := range votes {
+		if err := fn(v.Seq, v); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // Before returns true if tx1 has a lower sequence number than tx2.
@@ -150,7 +515,10 @@ func (p *Peer) Before(tx1, tx2 Tx) bool {
 		panic("labels can't be different")
 	}
 
-	bucket := p.bucket(tx1.Label())
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	bucket := p.readBucket(tx1.Label())
 	hash1, hash2 := tx1.Hash(), tx2.Hash()
 
 	_, c1 := bucket.commitedHashes[hash1]
@@ -171,12 +539,11 @@ func (p *Peer) Before(tx1, tx2 Tx) bool {
 		return false
 	}
 
-	v1 := bucket.votes.First(elementByHash(hash1))
-	v2 := bucket.votes.First(elementByHash(hash2))
+	v1, v2 := bucket.vote(hash1), bucket.vote(hash2)
 
 	// both voted
 	if v1 != nil && v2 != nil {
-		return v1.Value.(*Vote).Seq < v2.Value.(*Vote).Seq
+		return v1.Seq < v2.Seq
 	}
 
 	// tx1 voted and tx2 not voted
@@ -190,23 +557,101 @@ func (p *Peer) Before(tx1, tx2 Tx) bool {
 // Seen returns whether a tx has been voted for or not.
 // A Tx considered as seen iff there are no gaps befre the votes's seq number.
 func (p *Peer) Seen(tx Tx) bool {
-	bucket := p.bucket(tx.Label())
-	hash := tx.Hash()
-	item := bucket.votes.First(elementByHash(hash))
-	if item == nil {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	bucket := p.readBucket(tx.Label())
+	v := bucket.vote(tx.Hash())
+	if v == nil {
 		return false
 	}
 
-	return item.Value.(*Vote).Seq <= bucket.lastSeqSeen
+	return v.Seq <= bucket.lastSeqSeen
+}
+
+// Voted returns true if the peer has voted for the tx, regardless of
+// whether the votes with lower sequence numbers have been received (see Seen).
+func (p *Peer) Voted(tx Tx) bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.readBucket(tx.Label()).vote(tx.Hash()) != nil
+}
+
+// Position returns the sequence number with which the peer voted for the tx,
+// it returns false if the peer hasn't voted for it (see Voted).
+// Position is the raw signal behind Before and Seen.
+func (p *Peer) Position(tx Tx) (uint64, bool) {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	v := p.readBucket(tx.Label()).vote(tx.Hash())
+	if v == nil {
+		return 0, false
+	}
+	return v.Seq, true
+}
+
+// rekey replaces the peer's key, its votes are replaced by copies carrying
+// the new Pubkey, which are returned.
+func (p *Peer) rekey(pub Pubkey) []*Vote {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.pub = pub
+	var votes []*Vote
+	for _, bucket := range p.buckets {
+		old := bucket.votes
+		bucket.votes = list.New()
+		bucket.byHash = make(map[Hash]*list.Element)
+		old.Each(func(e *list.Element) bool {
+			v := *e.Value.(*Vote)
+			v.Pubkey = pub
+			bucket.index(bucket.votes.PushBack(&v))
+			votes = append(votes, &v)
+			return true
+		})
+	}
+	return votes
+}
+
+// vote returns the vote for the tx with the given hash and label, or nil if
+// the peer hasn't voted for it.
+func (p *Peer) vote(label string, hash Hash) *Vote {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.readBucket(label).vote(hash)
+}
+
+// voted is like Voted, but it looks up the tx by hash in every bucket.
+func (p *Peer) voted(hash Hash) bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, bucket := range p.buckets {
+		if bucket.vote(hash) != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // UpdateTxSet will remove from its internal state all the references to a
 // corresponging tx present in the txs argument.
+// The votes for those txs are dropped, only the fact that they were commited
+// is kept.
 // updateTxSet should be called when a new block is commited.
 // NOTE: This should interface a blockchain implementation to keep track of
 // commited Txs.
 func (p *Peer) UpdateTxSet(txs ...Tx) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
 	for _, tx := range txs {
-		p.bucket(tx.Label()).commitedHashes[tx.Hash()] = struct{}{}
+		bucket := p.bucket(tx.Label())
+		hash := tx.Hash()
+		bucket.commitedHashes[hash] = struct{}{}
+		bucket.discard(hash)
 	}
 }
@@ -61,6 +61,152 @@ func TestPeersVoting(t *testing.T) {
 	})
 }
 
+func TestPeerEquivocation(t *testing.T) {
+	s := newTestPeer()
+	require.NoError(t, s.AddVotes(testVote0, testVote1))
+
+	conflicting := NewVote(pub0, 1, testTx2).WithPrevHash(testVote0.Hash())
+	added, err := s.AddVote(conflicting)
+	require.NoError(t, err)
+	assert.False(t, added, "conflicting vote should not be added")
+	assert.True(t, s.Seen(testTx1), "original vote should not be overwritten")
+
+	// adding the same conflicting vote twice should report it only once.
+	_, err = s.AddVote(conflicting)
+	require.NoError(t, err)
+
+	require.Equal(t, []EquivocationProof{
+		{First: testVote1, Second: conflicting},
+	}, s.Equivocations())
+}
+
+func TestPeerGaps(t *testing.T) {
+	s := newTestPeer()
+	assert.Empty(t, s.Gaps(""))
+	assert.True(t, s.LastSeen().IsZero())
+
+	require.NoError(t, s.AddVotes(testVote0, testVote1))
+	assert.Empty(t, s.Gaps(""))
+	assert.False(t, s.LastSeen().IsZero())
+
+	seq6 := NewVote(pub0, 6, testTx5)
+	require.NoError(t, s.AddVotes(testVote3, seq6))
+	assert.Equal(t, []uint64{2, 4, 5}, s.Gaps(""))
+	assert.True(t, s.Voted(testTx3))
+	assert.False(t, s.Seen(testTx3))
+	assert.False(t, s.Voted(testTx2))
+
+	require.NoError(t, s.AddVotes(testVote2))
+	assert.Equal(t, []uint64{4, 5}, s.Gaps(""))
+	assert.Empty(t, s.Gaps("other-label"))
+}
+
+func TestPeerReplayOrdered(t *testing.T) {
+	s := newTestPeer()
+	seq6 := NewVote(pub0, 6, testTx5)
+	labeled := NewVote(pub0, 0, NewSimpleTx("tx", "hash").withLabel("label"))
+	// votes are added out of order, with a gap.
+	require.NoError(t, s.AddVotes(seq6, testVote1, labeled, testVote0))
+
+	var seqs []uint64
+	var labels []string
+	require.NoError(t, s.ReplayOrdered(func(seq uint64, v *Vote) error {
+		seqs = append(seqs, seq)
+		labels = append(labels, v.Label)
+		return nil
+	}))
+	assert.Equal(t, []uint64{0, 1, 6, 0}, seqs)
+	assert.Equal(t, []string{"", "", "", "label"}, labels)
+
+	t.Run("StopsOnError", func(t *testing.T) {
+		var n int
+		err := s.ReplayOrdered(func(seq uint64, v *Vote) error {
+			n++
+			if seq == 1 {
+				return ErrVoteHashesDontMatch
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrVoteHashesDontMatch)
+		assert.Equal(t, 2, n)
+	})
+}
+
+func TestPeerSequenceWindow(t *testing.T) {
+	s := newTestPeer()
+	s.SetSequenceWindow(2)
+
+	ok, err := s.AddVote(NewVote(pub0, 3, testTx3))
+	require.NoError(t, err)
+	assert.False(t, ok, "seq 3 is beyond the window")
+	assert.True(t, s.LastSeen().IsZero(), "rejected votes don't count as seen")
+	assert.Empty(t, s.buckets, "rejected votes don't create buckets")
+
+	ok, err = s.AddVote(NewVote(pub0, 2, testTx2))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.AddVote(NewVote(pub0, 4, testTx4))
+	require.NoError(t, err)
+	assert.True(t, ok, "seq 4 is within the window of seq 2")
+	assert.Equal(t, []uint64{1, 3}, s.Gaps(""))
+
+	// votes with lower seq numbers are always accepted.
+	ok, err = s.AddVote(NewVote(pub0, 0, testTx0))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{0, 2, 4}, voteSeqs(s.Votes()))
+}
+
+func voteSeqs(votes []*Vote) []uint64 {
+	seqs := make([]uint64, 0, len(votes))
+	for _, v := range votes {
+		seqs = append(seqs, v.Seq)
+	}
+	return seqs
+}
+
+func TestPeerRemoveVote(t *testing.T) {
+	s := newTestPeer()
+	require.NoError(t,
+		s.AddVotes(testVote0, testVote1, testVote2),
+	)
+
+	require.True(t, s.RemoveVote(testTx1.Hash()))
+	require.False(t, s.RemoveVote(testTx1.Hash()))
+
+	assert.False(t, s.Seen(testTx1))
+	assert.True(t, s.Before(testTx0, testTx2))
+}
+
+func TestPeerVotedTwice(t *testing.T) {
+	s := newTestPeer()
+	// the same tx is voted with seqs 1 and 3, the lowest one is used.
+	require.NoError(t, s.AddVotes(
+		NewVote(s.pub, 3, testTx0),
+		NewVote(s.pub, 1, testTx0),
+		NewVote(s.pub, 5, testTx1),
+	))
+	assert.True(t, s.Before(testTx0, testTx1))
+	seq, ok := s.Position(testTx0)
+	require.True(t, ok)
+	assert.Equal(t, uint64(1), seq)
+
+	// once the first vote is removed the second one is used.
+	require.True(t, s.RemoveVote(testTx0.Hash()))
+	assert.True(t, s.Voted(testTx0))
+	assert.True(t, s.Before(testTx0, testTx1))
+	seq, ok = s.Position(testTx0)
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), seq)
+
+	require.True(t, s.RemoveVote(testTx0.Hash()))
+	assert.False(t, s.Voted(testTx0))
+	assert.False(t, s.Before(testTx0, testTx1))
+	_, ok = s.Position(testTx0)
+	assert.False(t, ok)
+}
+
 func TestBefore(t *testing.T) {
 	// List of priorities to evaluate t1 before t2
 	//                               (t2)
@@ -0,0 +1,143 @@
+package wendy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+
+	protowendy "github.com/vegaprotocol/wendy/proto/wendy"
+)
+
+// ToProto converts a Vote into its protobuf representation.
+func (v *Vote) ToProto() *protowendy.Vote {
+	return &protowendy.Vote{
+		Sender:   v.Pubkey.String(),
+		Sequence: v.Seq,
+		TxHash:   v.TxHash[:],
+		Seen:     timestamppb.New(v.Time),
+		Label:    v.Label,
+		PrevHash: v.PrevHash[:],
+	}
+}
+
+// VoteFromProto converts a protobuf Vote into a Vote.
+// It returns an error if the sender is not a valid pubkey or if the hashes
+// don't have the right length.
+func VoteFromProto(m *protowendy.Vote) (*Vote, error) {
+	if m == nil {
+		return nil, fmt.Errorf("%w: vote is nil", ErrInvalidEncoding)
+	}
+
+	pub, err := hex.DecodeString(strings.TrimPrefix(m.Sender, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: sender: %v", ErrInvalidEncoding, err)
+	}
+	if len(pub) == 0 {
+		return nil, fmt.Errorf("%w: sender is empty", ErrInvalidEncoding)
+	}
+
+	txHash, err := hashFromProto("tx_hash", m.TxHash)
+	if err != nil {
+		return nil, err
+	}
+
+	prevHash, err := hashFromProto("prev_hash", m.PrevHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Seen.CheckValid(); err != nil {
+		return nil, fmt.Errorf("%w: seen: %v", ErrInvalidEncoding, err)
+	}
+
+	return &Vote{
+		Pubkey:   pub,
+		Label:    m.Label,
+		Seq:      m.Sequence,
+		TxHash:   txHash,
+		Time:     m.Seen.AsTime(),
+		PrevHash: prevHash,
+	}, nil
+}
+
+// ToProto converts a SignedVote into its protobuf representation.
+func (sv *SignedVote) ToProto() *protowendy.SignedVote {
+	return &protowendy.SignedVote{
+		Signature: sv.Signature,
+		Data:      sv.Data.ToProto(),
+		Scheme:    uint32(sv.Scheme),
+	}
+}
+
+// SignedVoteFromProto converts a protobuf SignedVote into a SignedVote.
+// Messages without a scheme are ed25519 signed (see Scheme), it returns an
+// error if the scheme doesn't fit in a Scheme.
+func SignedVoteFromProto(m *protowendy.SignedVote) (*SignedVote, error) {
+	if m == nil {
+		return nil, fmt.Errorf("%w: signed vote is nil", ErrInvalidEncoding)
+	}
+	if m.Scheme > math.MaxUint8 {
+		return nil, fmt.Errorf("%w: scheme %d is out of range", ErrInvalidEncoding, m.Scheme)
+	}
+
+	vote, err := VoteFromProto(m.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedVote{
+		Scheme:    Scheme(m.Scheme),
+		Signature: m.Signature,
+		Data:      vote,
+	}, nil
+}
+
+// ToProto converts a Block into its protobuf representation.
+func (b *Block) ToProto() *protowendy.Block {
+	txs := make([]*protowendy.Tx, 0, len(b.Txs))
+	for _, tx := range b.Txs {
+		hash := tx.Hash()
+		txs = append(txs, &protowendy.Tx{
+			Data:  tx.Bytes(),
+			Hash:  hash[:],
+			Label: tx.Label(),
+		})
+	}
+	return &protowendy.Block{Txs: txs}
+}
+
+// BlockFromProto converts a protobuf Block into a Block.
+// Since the Tx implementation is unknown, Block's txs are represented by a
+// Tx implementation which holds the tx's bytes, hash and label.
+func BlockFromProto(m *protowendy.Block) (*Block, error) {
+	if m == nil {
+		return nil, fmt.Errorf("%w: block is nil", ErrInvalidEncoding)
+	}
+
+	txs := make([]Tx, 0, len(m.Txs))
+	for i, tx := range m.Txs {
+		hash, err := hashFromProto(fmt.Sprintf("txs[%d].hash", i), tx.GetHash())
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, &decodedTx{
+			bytes: tx.GetData(),
+			hash:  hash,
+			label: tx.GetLabel(),
+		})
+	}
+	return &Block{Txs: txs}, nil
+}
+
+// hashFromProto turns bz into a Hash, it returns an error if the length of bz
+// is not HashLen.
+func hashFromProto(field string, bz []byte) (Hash, error) {
+	hash, err := HashFromBytes(bz)
+	if err != nil {
+		return hash, fmt.Errorf("%w: %s: %v", ErrInvalidEncoding, field, err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,91 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	protowendy "github.com/vegaprotocol/wendy/proto/wendy"
+)
+
+func TestProto(t *testing.T) {
+	t.Run("Vote", func(t *testing.T) {
+		for _, vote := range []*Vote{
+			NewVote(pub0, 0, testTx0),
+			NewVote(pub1, 1, NewSimpleTx("tx1", "h1").withLabel("label")).WithPrevHash(testVote0.Hash()),
+		} {
+			bz := protowendy.MustMarshal(vote.ToProto())
+
+			m := &protowendy.Vote{}
+			protowendy.MustUnmarshal(bz, m)
+
+			got, err := VoteFromProto(m)
+			require.NoError(t, err)
+			assert.Equal(t, vote.Hash(), got.Hash())
+			assert.Equal(t, vote.Pubkey, got.Pubkey)
+			assert.Equal(t, vote.Label, got.Label)
+		}
+	})
+
+	t.Run("SignedVote", func(t *testing.T) {
+		pub, priv, err := ed25519.GenerateKey(Rand)
+		require.NoError(t, err)
+		sv := NewSignedVote(priv, NewVote(Pubkey(pub), 0, testTx0))
+
+		got, err := SignedVoteFromProto(sv.ToProto())
+		require.NoError(t, err)
+		assert.Equal(t, Ed25519, got.Scheme)
+		assert.True(t, got.Verify())
+
+		t.Run("Scheme", func(t *testing.T) {
+			sv, err := SignVote(testSigner(pub), NewVote(Pubkey(pub), 0, testTx0))
+			require.NoError(t, err)
+
+			bz := protowendy.MustMarshal(sv.ToProto())
+			m := &protowendy.SignedVote{}
+			protowendy.MustUnmarshal(bz, m)
+
+			got, err := SignedVoteFromProto(m)
+			require.NoError(t, err)
+			assert.Equal(t, testScheme, got.Scheme)
+			assert.Equal(t, sv.Signature, got.Signature)
+			assert.Equal(t, sv.Data.Hash(), got.Data.Hash())
+
+			m.Scheme = 0x100
+			_, err = SignedVoteFromProto(m)
+			assert.ErrorIs(t, err, ErrInvalidEncoding)
+		})
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		block := &Block{Txs: allTestTxs}
+
+		got, err := BlockFromProto(block.ToProto())
+		require.NoError(t, err)
+		require.Len(t, got.Txs, len(allTestTxs))
+		for i, tx := range allTestTxs {
+			assert.Equal(t, tx.Hash(), got.Txs[i].Hash())
+			assert.Equal(t, tx.Bytes(), got.Txs[i].Bytes())
+			assert.Equal(t, tx.Label(), got.Txs[i].Label())
+		}
+	})
+
+	t.Run("InvalidLengths", func(t *testing.T) {
+		m := testVote0.ToProto()
+		m.TxHash = m.TxHash[:10]
+		_, err := VoteFromProto(m)
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+		m = testVote0.ToProto()
+		m.Sender = ""
+		_, err = VoteFromProto(m)
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+		b := (&Block{Txs: allTestTxs}).ToProto()
+		b.Txs[0].Hash = nil
+		_, err = BlockFromProto(b)
+		assert.ErrorIs(t, err, ErrInvalidEncoding)
+	})
+}
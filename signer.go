@@ -0,0 +1,95 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+)
+
+// Scheme identifies the signature scheme of a SignedVote.
+type Scheme byte
+
+const (
+	// Ed25519 is the default signature scheme, it's the zero value so that
+	// signed votes without a scheme are ed25519 signed.
+	Ed25519 Scheme = iota
+)
+
+func (s Scheme) String() string {
+	if s == Ed25519 {
+		return "ed25519"
+	}
+	return fmt.Sprintf("scheme(%d)", byte(s))
+}
+
+// ErrUnknownScheme is returned by SignedVote.VerifyE when there's no Verifier
+// registered for the scheme of the vote, see RegisterVerifier.
+var ErrUnknownScheme = fmt.Errorf("%w: unknown signature scheme", ErrInvalidSignature)
+
+// Signer signs votes (see SignVote) with a signature scheme.
+type Signer interface {
+	Scheme() Scheme
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier verifies the signatures of a signature scheme.
+// Verify returns nil if sig is a valid signature of msg by pub, otherwise it
+// returns an error wrapping ErrInvalidSignature.
+type Verifier interface {
+	Scheme() Scheme
+	Verify(pub Pubkey, msg, sig []byte) error
+}
+
+var (
+	verifiersMtx sync.RWMutex
+	verifiers    = map[Scheme]Verifier{
+		Ed25519: Ed25519Verifier{},
+	}
+)
+
+// RegisterVerifier registers v as the Verifier for its scheme, replacing the
+// previous one if any, so that the signed votes of the scheme can be
+// verified, e.g to plug in secp256k1.
+// The Ed25519 Verifier is registered by default.
+func RegisterVerifier(v Verifier) {
+	verifiersMtx.Lock()
+	defer verifiersMtx.Unlock()
+
+	verifiers[v.Scheme()] = v
+}
+
+// verifierFor returns the Verifier registered for s.
+func verifierFor(s Scheme) (Verifier, bool) {
+	verifiersMtx.RLock()
+	defer verifiersMtx.RUnlock()
+
+	v, ok := verifiers[s]
+	return v, ok
+}
+
+// Ed25519Signer is the Signer of the Ed25519 scheme.
+type Ed25519Signer ed25519.PrivateKey
+
+func (Ed25519Signer) Scheme() Scheme { return Ed25519 }
+
+func (s Ed25519Signer) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s), msg), nil
+}
+
+// Ed25519Verifier is the Verifier of the Ed25519 scheme.
+// It returns ErrPubkeyLen if pub is not a valid ed25519 public key or
+// ErrBadSignature if the signature doesn't match.
+type Ed25519Verifier struct{}
+
+func (Ed25519Verifier) Scheme() Scheme { return Ed25519 }
+
+func (Ed25519Verifier) Verify(pub Pubkey, msg, sig []byte) error {
+	if l := len(pub); l != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrPubkeyLen, ed25519.PublicKeySize, l)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pub), msg, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package wendy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testScheme is a fake signature scheme where the signature is the checksum
+// of the pubkey and the message.
+const testScheme Scheme = 0xff
+
+type testSigner Pubkey
+
+func (testSigner) Scheme() Scheme { return testScheme }
+
+func (s testSigner) Sign(msg []byte) ([]byte, error) {
+	sum := Checksum(append(append([]byte{}, s...), msg...))
+	return sum[:], nil
+}
+
+type testVerifier struct{}
+
+func (testVerifier) Scheme() Scheme { return testScheme }
+
+func (testVerifier) Verify(pub Pubkey, msg, sig []byte) error {
+	if want, _ := testSigner(pub).Sign(msg); !bytes.Equal(want, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func TestSignVote(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+
+	ed, err := SignVote(Ed25519Signer(priv), NewVote(Pubkey(pub), 0, testTx0))
+	require.NoError(t, err)
+	assert.Equal(t, Ed25519, ed.Scheme)
+	assert.NoError(t, ed.VerifyE())
+
+	fake, err := SignVote(testSigner(pub), NewVote(Pubkey(pub), 0, testTx0))
+	require.NoError(t, err)
+	assert.ErrorIs(t, fake.VerifyE(), ErrUnknownScheme, "not registered yet")
+
+	RegisterVerifier(testVerifier{})
+	assert.NoError(t, fake.VerifyE())
+
+	t.Run("CrossScheme", func(t *testing.T) {
+		wrong := *fake
+		wrong.Scheme = Ed25519
+		assert.ErrorIs(t, wrong.VerifyE(), ErrBadSignature)
+
+		wrong = *ed
+		wrong.Scheme = testScheme
+		assert.ErrorIs(t, wrong.VerifyE(), ErrBadSignature)
+	})
+
+	t.Run("Marshal", func(t *testing.T) {
+		bz, err := fake.Marshal()
+		require.NoError(t, err)
+
+		got, err := UnmarshalSignedVote(bz)
+		require.NoError(t, err)
+		assert.Equal(t, testScheme, got.Scheme)
+		assert.NoError(t, got.VerifyE())
+	})
+}
@@ -47,7 +47,7 @@ func NewNetwork(topology map[wendy.ID][]wendy.ID) *Network {
 	// Collect the validators and update the validator set on every node
 	validators := make([]wendy.Validator, 0, len(nodes))
 	for id := range nodes {
-		validators = append(validators, wendy.Validator(id))
+		validators = append(validators, wendy.Validator(wendy.NewPubkeyFromID(id)))
 	}
 
 	net := &Network{
@@ -0,0 +1,179 @@
+package wendy
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format.
+// It needs to be increased every time the format changes.
+const snapshotVersion = 4
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// snapshot is the serializable representation of Wendy's state.
+type snapshot struct {
+	Version    uint32
+	Validators []Validator
+	Weights    map[ID]uint64
+	Txs        []snapshotTx
+	Votes      []*Vote
+	Peers      []snapshotPeer
+}
+
+type snapshotTx struct {
+	Bytes    []byte
+	Hash     Hash
+	Label    string
+	Deadline time.Time // Deadline is zero for txs without deadline.
+	After    []Hash    // After holds the declared predecessors, see AddTxWithDeps.
+	Round    int64     // Round is only set if HasRound, see AddTxWithRound.
+	HasRound bool
+}
+
+type snapshotPeer struct {
+	Pubkey  Pubkey
+	Weight  uint64
+	Buckets map[string]snapshotBucket
+}
+
+type snapshotBucket struct {
+	Votes          []*Vote
+	LastSeqSeen    uint64
+	CommitedHashes []Hash
+}
+
+var _ Tx = &decodedTx{}
+
+// decodedTx is the Tx implementation used for the txs decoded from a snapshot
+// or a protobuf message, since the original Tx implementation is unknown to
+// Wendy.
+type decodedTx struct {
+	bytes []byte
+	hash  Hash
+	label string
+}
+
+func (tx *decodedTx) Bytes() []byte { return tx.bytes }
+func (tx *decodedTx) Hash() Hash    { return tx.hash }
+func (tx *decodedTx) Label() string { return tx.label }
+
+// Snapshot serializes the state of Wendy, this includes the validator set,
+// the txs, the votes and the state of every peer.
+// The snapshot is versioned so that format changes can be detected by
+// Restore.
+func (w *Wendy) Snapshot() ([]byte, error) {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	snap := snapshot{
+		Version:    snapshotVersion,
+		Validators: w.validators,
+		Weights:    w.weights,
+	}
+
+	for _, tx := range w.txs.List() {
+		stx := snapshotTx{
+			Bytes: tx.Bytes(), Hash: tx.Hash(), Label: tx.Label(),
+			Deadline: w.deadlines[tx.Hash()],
+			After:    w.deps[tx.Hash()],
+		}
+		if round, ok := w.rounds[tx.Hash()]; ok {
+			stx.Round, stx.HasRound = round, true
+		}
+		snap.Txs = append(snap.Txs, stx)
+	}
+
+	for _, vote := range w.votes {
+		snap.Votes = append(snap.Votes, vote)
+	}
+
+	for _, peer := range w.peers {
+		snap.Peers = append(snap.Peers, peer.snapshot())
+	}
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Restore restores Wendy's state from a snapshot produced by Snapshot.
+// If the validator set has already been set, it is kept, otherwise the
+// validator set from the snapshot is used.
+// Peers and votes from validators that are not part of the validator set are
+// discarded.
+func (w *Wendy) Restore(bz []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(bz)).Decode(&snap); err != nil {
+		return err
+	}
+
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, snap.Version)
+	}
+
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	txs := NewTxs()
+	deadlines := make(map[Hash]time.Time)
+	deps := make(map[Hash][]Hash)
+	rounds := make(map[Hash]int64)
+	for _, tx := range snap.Txs {
+		txs.Push(&decodedTx{bytes: tx.Bytes, hash: tx.Hash, label: tx.Label})
+		if !tx.Deadline.IsZero() {
+			deadlines[tx.Hash] = tx.Deadline
+		}
+		if len(tx.After) > 0 {
+			deps[tx.Hash] = tx.After
+		}
+		if tx.HasRound {
+			rounds[tx.Hash] = tx.Round
+		}
+	}
+	w.txs, w.deadlines, w.deps, w.rounds = txs, deadlines, deps, rounds
+
+	w.peers = make(map[ID]*Peer)
+	w.stale = make(map[ID]struct{})
+	for _, p := range snap.Peers {
+		peer := newPeerFromSnapshot(p)
+		peer.SetSequenceWindow(w.seqWindow)
+		w.peers[ID(peer.pub.String())] = peer
+	}
+
+	validators, weights := w.validators, w.weights
+	if len(validators) == 0 {
+		validators, weights = snap.Validators, snap.Weights
+	}
+	w.setValidatorSet(validators, weights)
+	// transitions are not part of the snapshot.
+	w.transition = nil
+
+	w.votes = make(map[Hash]*Vote)
+	// arrivals are local and not part of the snapshot.
+	w.arrivals = make(map[Hash]time.Time)
+	w.eligibleAt = make(map[Hash]time.Time)
+	// signatures are not part of the snapshot either, restored votes can't
+	// be part of a Certificate nor an Evidence.
+	w.signatures = make(map[Hash]map[ID]*SignedVote)
+	w.evidence = make(map[ID]*Evidence)
+	w.voteCache.reset()
+	for _, vote := range snap.Votes {
+		if _, ok := w.peers[vote.Key()]; ok {
+			w.votes[vote.TxHash] = vote
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package wendy
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	allTxs := []Tx{testTx0, testTx1, testTx2}
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: allTxs,
+			&pub1: allTxs,
+			&pub2: {testTx2, testTx1, testTx0},
+		},
+	)
+
+	bz, err := w.Snapshot()
+	require.NoError(t, err)
+
+	restored := New()
+	require.NoError(t, restored.Restore(bz))
+
+	assert.Equal(t, w.Quorum(), restored.Quorum())
+	assert.Equal(t, w.ValidatorCount(), restored.ValidatorCount())
+	for _, tx1 := range allTxs {
+		assert.Equal(t, w.IsBlocked(tx1), restored.IsBlocked(tx1))
+		assert.Equal(t, w.VoteByTxHash(tx1.Hash()).Hash(), restored.VoteByTxHash(tx1.Hash()).Hash())
+		for _, tx2 := range allTxs {
+			assert.Equal(t, w.IsBlockedBy(tx1, tx2), restored.IsBlockedBy(tx1, tx2))
+		}
+	}
+
+	var hashes []Hash
+	for _, tx := range restored.NewBlock().Txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	assert.ElementsMatch(t, []Hash{testTx0.Hash(), testTx1.Hash(), testTx2.Hash()}, hashes)
+
+	t.Run("Deadlines", func(t *testing.T) {
+		now := time.Now()
+		w.AddTxWithDeadline(testTx3, now)
+
+		bz, err := w.Snapshot()
+		require.NoError(t, err)
+
+		restored := New()
+		require.NoError(t, restored.Restore(bz))
+		assert.Equal(t, 0, restored.EvictExpired(now))
+		assert.Equal(t, 1, restored.EvictExpired(now.Add(time.Second)))
+	})
+
+	t.Run("Deps", func(t *testing.T) {
+		w := New()
+		w.AddTx(testTx1)
+		w.AddTxWithDeps(testTx0, []Hash{testTx1.Hash()})
+
+		bz, err := w.Snapshot()
+		require.NoError(t, err)
+
+		restored := New()
+		require.NoError(t, restored.Restore(bz))
+		assert.Equal(t, w.deps, restored.deps)
+		assert.Len(t, restored.BlockingSet()[testTx0.Hash()], 2)
+	})
+
+	t.Run("Rounds", func(t *testing.T) {
+		w := New()
+		w.AddTx(testTx0)
+		w.AddTxWithRound(testTx1, 0)
+		w.AddTxWithRound(testTx2, 3)
+
+		bz, err := w.Snapshot()
+		require.NoError(t, err)
+
+		restored := New()
+		require.NoError(t, restored.Restore(bz))
+		assert.Equal(t, map[Hash]int64{testTx1.Hash(): 0, testTx2.Hash(): 3}, restored.rounds)
+	})
+
+	t.Run("DiscardsUnknownValidators", func(t *testing.T) {
+		restored := New()
+		restored.UpdateValidatorSet([]Validator{pub0.Bytes(), pub3.Bytes()})
+		require.NoError(t, restored.Restore(bz))
+
+		assert.Equal(t, 2, restored.ValidatorCount())
+		assert.True(t, restored.IsBlocked(testTx0))
+		assert.True(t, restored.peers[ID(pub0.String())].Seen(testTx0))
+		assert.NotContains(t, restored.peers, ID(pub1.String()))
+	})
+
+	t.Run("WrongVersion", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t,
+			gob.NewEncoder(buf).Encode(snapshot{Version: snapshotVersion + 1}),
+		)
+		assert.ErrorIs(t, New().Restore(buf.Bytes()), ErrSnapshotVersion)
+	})
+}
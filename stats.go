@@ -0,0 +1,43 @@
+package wendy
+
+// Stats is a snapshot of Wendy's counters.
+type Stats struct {
+	NumTxs        int // NumTxs is the number of pending txs.
+	NumVotes      int // NumVotes is the number of txs that have been voted.
+	NumPeers      int // NumPeers is the number of peers, validators or not.
+	Quorum        int
+	NumValidators int
+	NumBlocked    int // NumBlocked is the number of pending txs that are blocked.
+
+	// NumDuplicateVotes is the number of votes received more than once, see
+	// DuplicateVoteCount.
+	NumDuplicateVotes uint64
+}
+
+// Stats returns a consistent snapshot of Wendy's counters, all of them are
+// computed under the same lock.
+func (w *Wendy) Stats() Stats {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	stats := Stats{
+		NumTxs:        len(w.txs.List()),
+		NumVotes:      len(w.votes),
+		NumPeers:      len(w.peers),
+		Quorum:        int(w.quorum),
+		NumValidators: len(w.validators),
+
+		NumDuplicateVotes: w.duplicateVotes,
+	}
+
+	for _, tx := range w.txs.List() {
+		if w.isBlocked(tx) {
+			stats.NumBlocked++
+		}
+	}
+
+	return stats
+}
@@ -2,26 +2,124 @@ package app
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/tendermint/tendermint/mempool"
+	"github.com/tendermint/tendermint/types"
 
 	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/vegaprotocol/wendy"
 )
 
 type App struct {
 	abci.BaseApplication
 	mempool mempool.Mempool
+
+	// wendy is the fairness layer, it is optional.
+	wendy *wendy.Wendy
+
+	// delivered holds the txs delivered on the current block, they are
+	// committed to wendy on Commit.
+	deliveredMtx sync.Mutex
+	delivered    []wendy.Tx
 }
 
 func New() *App {
 	return &App{}
 }
 
+// WithWendy plugs the Wendy fairness layer into the app.
+// Txs are added to Wendy on CheckTx and pruned from it once they have been
+// committed.
+func (app *App) WithWendy(w *wendy.Wendy) *App {
+	app.wendy = w
+	return app
+}
+
 func (app *App) SetMempool(mp mempool.Mempool) {
 	app.mempool = mp
 }
 
-func (ap *App) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
+// InitChain sets Wendy's validator set to the genesis validators, weighted by
+// their voting power, otherwise every tx would stay blocked.
+// The app doesn't return validator updates on EndBlock, hence the genesis
+// validators are kept for the lifetime of the chain.
+func (app *App) InitChain(req abci.RequestInitChain) abci.ResponseInitChain {
+	if app.wendy != nil {
+		weights := make(map[wendy.ID]uint64, len(req.Validators))
+		for _, v := range req.Validators {
+			pub := v.PubKey.GetEd25519()
+			if pub == nil {
+				pub = v.PubKey.GetSecp256K1()
+			}
+			if len(pub) == 0 || v.Power <= 0 {
+				continue
+			}
+			weights[wendy.ID(wendy.Pubkey(pub).String())] = uint64(v.Power)
+		}
+		app.wendy.UpdateValidatorSetWeighted(weights)
+	}
+	return abci.ResponseInitChain{}
+}
+
+func (app *App) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 	fmt.Printf("CheckTx(%8s): (%s)\n", req.Type, string(req.Tx))
+	if app.wendy != nil {
+		app.wendy.AddTx(newTx(req.Tx))
+	}
 	return abci.ResponseCheckTx{Code: abci.CodeTypeOK}
 }
+
+func (app *App) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliverTx {
+	if app.wendy != nil {
+		app.deliveredMtx.Lock()
+		app.delivered = append(app.delivered, newTx(req.Tx))
+		app.deliveredMtx.Unlock()
+	}
+	return abci.ResponseDeliverTx{Code: abci.CodeTypeOK}
+}
+
+func (app *App) Commit() abci.ResponseCommit {
+	if app.wendy != nil {
+		app.deliveredMtx.Lock()
+		app.wendy.CommitBlock(wendy.Block{Txs: app.delivered})
+		app.delivered = nil
+		app.deliveredMtx.Unlock()
+	}
+	return abci.ResponseCommit{}
+}
+
+// AddVote adds a vote received from a peer (the sender) to Wendy.
+// It returns false if Wendy is not set or the vote was already added.
+func (app *App) AddVote(v *wendy.Vote) (bool, error) {
+	if app.wendy == nil {
+		return false, nil
+	}
+	return app.wendy.AddVote(v)
+}
+
+// NewBlock returns the set of txs that should be included on the next
+// proposal according to Wendy.
+// If Wendy is not set, it returns nil.
+func (app *App) NewBlock() *wendy.Block {
+	if app.wendy == nil {
+		return nil
+	}
+	return app.wendy.NewBlock()
+}
+
+var _ wendy.Tx = tx{}
+
+// tx wraps a Tendermint tx so that it implements wendy.Tx.
+type tx types.Tx
+
+func newTx(bz []byte) tx { return tx(bz) }
+
+func (tx tx) Bytes() []byte { return tx }
+func (tx tx) Label() string { return "" }
+func (tx tx) Hash() wendy.Hash {
+	var hash wendy.Hash
+	copy(hash[:], types.Tx(tx).Hash())
+	return hash
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vegaprotocol/wendy"
+)
+
+func TestAppWithWendy(t *testing.T) {
+	pubs := []wendy.Pubkey{
+		wendy.NewPubkeyFromID("0x00"),
+		wendy.NewPubkeyFromID("0x01"),
+		wendy.NewPubkeyFromID("0x02"),
+	}
+
+	w := wendy.New()
+	var vs []wendy.Validator
+	for _, pub := range pubs {
+		vs = append(vs, wendy.Validator(pub))
+	}
+	w.UpdateValidatorSet(vs)
+
+	app := New().WithWendy(w)
+
+	txs := [][]byte{[]byte("tx0"), []byte("tx1"), []byte("tx2")}
+	for _, bz := range txs {
+		res := app.CheckTx(abci.RequestCheckTx{Tx: bz})
+		require.Equal(t, abci.CodeTypeOK, res.Code)
+	}
+
+	for _, pub := range pubs {
+		var prev *wendy.Vote
+		for seq, bz := range txs {
+			vote := wendy.NewVote(pub, uint64(seq), newTx(bz))
+			if prev != nil {
+				vote.WithPrevHash(prev.Hash())
+			}
+			ok, err := app.AddVote(vote)
+			require.NoError(t, err)
+			require.True(t, ok)
+			prev = vote
+		}
+	}
+
+	block := app.NewBlock()
+	require.Len(t, block.Txs, 3)
+
+	// deliver and commit the first two txs only.
+	for _, bz := range txs[:2] {
+		res := app.DeliverTx(abci.RequestDeliverTx{Tx: bz})
+		require.Equal(t, abci.CodeTypeOK, res.Code)
+	}
+	app.Commit()
+
+	assert.Equal(t, 1, w.Stats().NumTxs)
+	assert.Nil(t, w.VoteByTxHash(newTx(txs[0]).Hash()))
+	assert.Nil(t, w.VoteByTxHash(newTx(txs[1]).Hash()))
+	assert.Equal(t, []wendy.Tx{newTx(txs[2])}, app.NewBlock().Txs)
+}
+
+func TestAppInitChain(t *testing.T) {
+	pubs := []wendy.Pubkey{
+		wendy.NewPubkeyFromID("0x00"),
+		wendy.NewPubkeyFromID("0x01"),
+	}
+
+	w := wendy.New()
+	app := New().WithWendy(w)
+	app.InitChain(abci.RequestInitChain{
+		Validators: []abci.ValidatorUpdate{
+			abci.Ed25519ValidatorUpdate(pubs[0], 1),
+			abci.Ed25519ValidatorUpdate(pubs[1], 3),
+		},
+	})
+
+	// only the second validator has a quorum on its own.
+	tx := newTx([]byte("tx0"))
+	app.CheckTx(abci.RequestCheckTx{Tx: tx})
+	_, err := app.AddVote(wendy.NewVote(pubs[0], 0, tx))
+	require.NoError(t, err)
+	require.True(t, w.IsBlocked(tx))
+
+	_, err = app.AddVote(wendy.NewVote(pubs[1], 0, tx))
+	require.NoError(t, err)
+	assert.False(t, w.IsBlocked(tx))
+}
+
+func TestAppWithoutWendy(t *testing.T) {
+	app := New()
+
+	res := app.CheckTx(abci.RequestCheckTx{Tx: []byte("tx0")})
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	app.DeliverTx(abci.RequestDeliverTx{Tx: []byte("tx0")})
+	app.Commit()
+
+	assert.Nil(t, app.NewBlock())
+}
+
+func TestAppStatusHandler(t *testing.T) {
+	pub := wendy.NewPubkeyFromID("0x00")
+	w := wendy.New()
+	w.UpdateValidatorSet([]wendy.Validator{wendy.Validator(pub)})
+
+	app := New().WithWendy(w)
+	app.CheckTx(abci.RequestCheckTx{Tx: []byte("tx0")})
+	app.CheckTx(abci.RequestCheckTx{Tx: []byte("tx1")})
+	// seq 1 is missing, hence tx1 is blocked.
+	_, err := app.AddVote(wendy.NewVote(pub, 2, newTx([]byte("tx1"))))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	app.StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, 2, status.NumTxs)
+	assert.Equal(t, 2, status.NumBlocked)
+	assert.Equal(t, 1, status.Quorum)
+	assert.Equal(t, map[wendy.ID][]uint64{wendy.ID(pub.String()): {1}}, status.Gaps)
+
+	rec = httptest.NewRecorder()
+	New().StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestAppProposal(t *testing.T) {
+	pubs := []wendy.Pubkey{
+		wendy.NewPubkeyFromID("0x00"),
+		wendy.NewPubkeyFromID("0x01"),
+		wendy.NewPubkeyFromID("0x02"),
+	}
+
+	w := wendy.New()
+	var vs []wendy.Validator
+	for _, pub := range pubs {
+		vs = append(vs, wendy.Validator(pub))
+	}
+	w.UpdateValidatorSet(vs)
+	app := New().WithWendy(w)
+
+	// every validator votes tx2, tx0 and tx1 in that order.
+	txs := [][]byte{[]byte("tx2"), []byte("tx0"), []byte("tx1")}
+	for _, bz := range txs {
+		app.CheckTx(abci.RequestCheckTx{Tx: bz})
+	}
+	for _, pub := range pubs {
+		var prev *wendy.Vote
+		for seq, bz := range txs {
+			vote := wendy.NewVote(pub, uint64(seq), newTx(bz))
+			if prev != nil {
+				vote.WithPrevHash(prev.Hash())
+			}
+			_, err := app.AddVote(vote)
+			require.NoError(t, err)
+			prev = vote
+		}
+	}
+
+	proposal := app.PrepareProposal(nil, 0)
+	assert.Equal(t, txs, proposal)
+	assert.True(t, app.ProcessProposal(proposal))
+
+	// tx1 is blocked by tx0, a malicious proposer can't place it first.
+	malicious := [][]byte{[]byte("tx2"), []byte("tx1"), []byte("tx0")}
+	assert.False(t, app.ProcessProposal(malicious))
+
+	t.Run("MaxTxBytes", func(t *testing.T) {
+		// tx0 and tx1 need tx2, which has to be included first.
+		assert.Equal(t, txs[:2], app.PrepareProposal(nil, 6))
+	})
+
+	t.Run("WithoutWendy", func(t *testing.T) {
+		app := New()
+		assert.Equal(t, txs[:2], app.PrepareProposal(txs, 6))
+		assert.True(t, app.ProcessProposal(malicious))
+	})
+}
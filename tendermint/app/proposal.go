@@ -0,0 +1,87 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/vegaprotocol/wendy"
+)
+
+// The following methods implement the fairness side of ABCI++
+// PrepareProposal and ProcessProposal, they are meant to be called from those
+// handlers, which the Tendermint version used by the app (v0.34) doesn't
+// provide yet.
+
+// PrepareProposal returns the txs the proposer should include in the next
+// block, in the order they should be included, given the txs proposed by
+// Tendermint and the maximum size in bytes of the block's txs (0 means no
+// limit).
+// With Wendy, the txs are selected by Wendy (see wendy.NewBlockFromSet) and
+// ordered so that no tx comes after a tx blocking it nor before its declared
+// predecessors (see wendy.Order), both out of the same BlockingSet, so that votes
+// added in between can't make them inconsistent. The given txs are ignored,
+// since Wendy holds every tx that passed CheckTx, the same txs as the
+// mempool, and dropping the ones Tendermint didn't propose would let their
+// blocked txs in without them. Without Wendy, the txs are returned in the
+// given order up to maxTxBytes.
+func (app *App) PrepareProposal(txs [][]byte, maxTxBytes int64) [][]byte {
+	if app.wendy == nil {
+		var (
+			proposal [][]byte
+			size     int64
+		)
+		for _, bz := range txs {
+			size += int64(len(bz))
+			if maxTxBytes > 0 && size > maxTxBytes {
+				break
+			}
+			proposal = append(proposal, bz)
+		}
+		return proposal
+	}
+
+	set := app.wendy.BlockingSet()
+	block := app.wendy.NewBlockFromSet(set, wendy.NewBlockOptions{
+		MaxBlockSize: int(maxTxBytes),
+	})
+	order, _ := app.wendy.Order(set)
+
+	// pos holds the position of every tx of the set in the blocking order.
+	pos := make(map[wendy.Hash]int, len(order))
+	for i, tx := range order {
+		pos[tx.Hash()] = i
+	}
+
+	sorted := block.Txs
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return pos[sorted[i].Hash()] < pos[sorted[j].Hash()]
+	})
+
+	proposal := make([][]byte, len(sorted))
+	for i, tx := range sorted {
+		proposal[i] = tx.Bytes()
+	}
+	return proposal
+}
+
+// ProcessProposal returns false if the proposed txs violate fairness, i.e if
+// a tx is placed before another tx that has priority over it: the former is
+// blocked by the latter but not the other way around (see
+// wendy.IsBlockedBy). Txs that block each other, e.g because they are part of
+// a fairness loop, can be proposed in any order.
+// Proposals are always accepted if Wendy is not set.
+func (app *App) ProcessProposal(txs [][]byte) bool {
+	if app.wendy == nil {
+		return true
+	}
+
+	for i := range txs {
+		tx1 := newTx(txs[i])
+		for j := i + 1; j < len(txs); j++ {
+			tx2 := newTx(txs[j])
+			if app.wendy.IsBlockedBy(tx1, tx2) && !app.wendy.IsBlockedBy(tx2, tx1) {
+				return false
+			}
+		}
+	}
+	return true
+}
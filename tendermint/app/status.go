@@ -0,0 +1,56 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vegaprotocol/wendy"
+)
+
+// StatusPath is the path StatusHandler is expected to be served on.
+const StatusPath = "/wendy/status"
+
+// Status is the fairness health of the app as reported by StatusHandler.
+type Status struct {
+	wendy.Stats
+
+	// Gaps holds the missing sequence numbers of every peer with gaps, see
+	// wendy.PeerGaps.
+	Gaps map[wendy.ID][]uint64
+}
+
+// Status returns the current status of Wendy.
+// It returns false if Wendy is not set.
+func (app *App) Status() (Status, bool) {
+	if app.wendy == nil {
+		return Status{}, false
+	}
+
+	return Status{
+		Stats: app.wendy.Stats(),
+		// the app's txs are not labeled.
+		Gaps: app.wendy.PeerGaps(""),
+	}, true
+}
+
+// StatusHandler returns an http.Handler that serves the app's Status as JSON.
+// It responds with 503 (Service Unavailable) if Wendy is not set.
+func (app *App) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		status, ok := app.Status()
+		if !ok {
+			http.Error(w, "wendy is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
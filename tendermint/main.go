@@ -15,6 +15,7 @@ import (
 	"github.com/tendermint/tendermint/privval"
 	"github.com/tendermint/tendermint/proxy"
 
+	"github.com/vegaprotocol/wendy"
 	"github.com/vegaprotocol/wendy/tendermint/app"
 	nm "github.com/vegaprotocol/wendy/tendermint/node"
 )
@@ -40,6 +41,14 @@ func newConfig(root string) *cfg.Config {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		if err := runStatus(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var root = "$HOME/.tendermint"
 	if len(os.Args) > 1 {
 		root = os.Args[1]
@@ -53,7 +62,7 @@ func main() {
 	filePV := privval.LoadOrGenFilePV(config.PrivValidatorKeyFile(), config.PrivValidatorStateFile())
 	logger := log.NewTMLogger(log.NewSyncWriter(os.Stdout))
 
-	app := app.New()
+	app := app.New().WithWendy(wendy.New())
 
 	node, err := nm.NewNode(
 		config,
@@ -82,6 +91,7 @@ func main() {
 		logger.Error("Error starting node", "err", err)
 		os.Exit(1)
 	}
+	serveStatus(app, logger.With("module", "main"))
 
 	node.Wait()
 }
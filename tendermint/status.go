@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/vegaprotocol/wendy"
+	"github.com/vegaprotocol/wendy/tendermint/app"
+)
+
+// statusListenAddrKey is the config key of the address the status handler
+// listens on.
+const statusListenAddrKey = "wendy.status_laddr"
+
+func init() {
+	viper.SetDefault(statusListenAddrKey, "127.0.0.1:26670")
+}
+
+// serveStatus serves the app's status on the address given by the config,
+// see app.StatusHandler.
+func serveStatus(a *app.App, logger log.Logger) {
+	addr := viper.GetString(statusListenAddrKey)
+	mux := http.NewServeMux()
+	mux.Handle(app.StatusPath, a.StatusHandler())
+
+	go func() {
+		logger.Info("Serving wendy status", "addr", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("Error serving wendy status", "err", err)
+		}
+	}()
+}
+
+// runStatus implements the `status` subcommand, it queries the status of a
+// running node and prints it.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	home := fs.String("home", "$HOME/.tendermint", "node's home directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	newConfig(os.ExpandEnv(*home))
+	url := "http://" + viper.GetString(statusListenAddrKey) + app.StatusPath
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("%s: %s", res.Status, body)
+	}
+
+	var status app.Status
+	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
+		return err
+	}
+	printStatus(os.Stdout, status)
+	return nil
+}
+
+func printStatus(w io.Writer, status app.Status) {
+	fmt.Fprintf(w, "pending txs:  %d\n", status.NumTxs)
+	fmt.Fprintf(w, "blocked txs:  %d\n", status.NumBlocked)
+	fmt.Fprintf(w, "voted txs:    %d\n", status.NumVotes)
+	fmt.Fprintf(w, "validators:   %d\n", status.NumValidators)
+	fmt.Fprintf(w, "peers:        %d\n", status.NumPeers)
+	fmt.Fprintf(w, "quorum:       %d\n", status.Quorum)
+
+	ids := make([]string, 0, len(status.Gaps))
+	for id := range status.Gaps {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	fmt.Fprintf(w, "peers with gaps: %d\n", len(ids))
+	for _, id := range ids {
+		fmt.Fprintf(w, "  %s: %v\n", id, status.Gaps[wendy.ID(id)])
+	}
+}
@@ -0,0 +1,105 @@
+package wendy
+
+// transition holds the validator set in place before the last validator set
+// update, which is still used to evaluate the txs that were pending at that
+// point, see WithGracefulTransition.
+type transition struct {
+	peers   map[ID]*Peer
+	peerIDs []ID // peerIDs holds the keys of peers sorted.
+	weights map[ID]uint64
+	quorum  uint64
+
+	// pending holds the txs evaluated against the previous validator set.
+	pending map[Hash]struct{}
+}
+
+// startTransition keeps the current validator set as the previous one before
+// it is updated. Any previous transition is discarded.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) startTransition() {
+	w.transition = nil
+	if !w.graceful || w.quorum == 0 || len(w.votes) == 0 {
+		return
+	}
+
+	t := &transition{
+		peers:   w.peers,
+		peerIDs: w.peerIDs,
+		weights: make(map[ID]uint64, len(w.peers)),
+		quorum:  w.quorum,
+		pending: make(map[Hash]struct{}, len(w.votes)),
+	}
+	// weights are copied since the peers kept by the new set are updated in
+	// place.
+	for id, peer := range w.peers {
+		t.weights[id] = peer.Weight()
+	}
+	for hash := range w.votes {
+		t.pending[hash] = struct{}{}
+	}
+	w.transition = t
+}
+
+// endTransition removes the tx from the transition, which ends once all its
+// txs are gone.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) endTransition(hash Hash) {
+	t := w.transition
+	if t == nil {
+		return
+	}
+
+	delete(t.pending, hash)
+	if len(t.pending) == 0 {
+		w.transition = nil
+		w.invalidateBlockingSet()
+	}
+}
+
+// removedPeers returns the peers of the previous validator set that are not
+// part of the current one.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) removedPeers() []*Peer {
+	if w.transition == nil {
+		return nil
+	}
+
+	var peers []*Peer
+	for id, peer := range w.transition.peers {
+		if _, ok := w.peers[id]; !ok {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// covers returns true if all the txs are evaluated by the transition.
+func (t *transition) covers(txs []Tx) bool {
+	if len(txs) == 0 {
+		return false
+	}
+	for _, tx := range txs {
+		if _, ok := t.pending[tx.Hash()]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// quorumReached is the equivalent of Wendy.quorumReached using the previous
+// validator set.
+func (t *transition) quorumReached(stale map[ID]struct{}, fn func(*Peer) bool) bool {
+	var votes uint64
+	for _, id := range t.peerIDs {
+		if _, ok := stale[id]; ok {
+			continue
+		}
+		if ok := fn(t.peers[id]); ok {
+			votes += t.weights[id]
+			if votes >= t.quorum {
+				return true
+			}
+		}
+	}
+	return false
+}
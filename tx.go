@@ -1,7 +1,9 @@
 package wendy
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 )
 
 var _ Tx = &SimpleTx{}
@@ -12,6 +14,10 @@ type SimpleTx struct {
 	label string
 }
 
+// NewSimpleTx returns a new SimpleTx.
+// Unlike HashFromBytes, hash is not required to be HashLen bytes long: shorter
+// hashes are zero padded and longer ones truncated, which is convenient for
+// testing but might lead to collisions.
 func NewSimpleTx(bytes, hash string) *SimpleTx {
 	return &SimpleTx{bytes: []byte(bytes), hash: []byte(hash)}
 }
@@ -33,6 +39,28 @@ func (tx *SimpleTx) String() string {
 	return fmt.Sprintf("%s (hash:%s)", string(tx.bytes), string(tx.hash))
 }
 
+var _ Tx = &RawTx{}
+
+// RawTx is a ready to use Tx implementation for raw byte transactions.
+type RawTx struct {
+	bytes []byte
+	hash  Hash
+	label string
+}
+
+// NewRawTx returns a new RawTx whose hash is computed once by hashFn.
+// If hashFn is nil, Checksum (SHA-256) is used.
+func NewRawTx(bytes []byte, hashFn func([]byte) Hash, label string) *RawTx {
+	if hashFn == nil {
+		hashFn = Checksum
+	}
+	return &RawTx{bytes: bytes, hash: hashFn(bytes), label: label}
+}
+
+func (tx *RawTx) Bytes() []byte { return tx.bytes }
+func (tx *RawTx) Hash() Hash    { return tx.hash }
+func (tx *RawTx) Label() string { return tx.label }
+
 // Txs keeps track of one or more Tx in order (.List()) but it also provide a
 // fast way to locate them by Hash (.ByHash).
 // Txs keeps unique transactions, and it discards added Txs that are duplicated.
@@ -115,3 +143,11 @@ func (txs *Txs) RemoveByHash(hash Hash) bool {
 	delete(txs.byHash, hash)
 	return true
 }
+
+// sortTxs sorts a list of txs by their hash.
+func sortTxs(txs []Tx) {
+	sort.Slice(txs, func(i, j int) bool {
+		h1, h2 := txs[i].Hash(), txs[j].Hash()
+		return bytes.Compare(h1[:], h2[:]) < 0
+	})
+}
@@ -75,3 +75,20 @@ func TestTxsRemoval(t *testing.T) {
 		})
 	}
 }
+
+func TestRawTx(t *testing.T) {
+	tx := NewRawTx([]byte("tx0"), nil, "A")
+	require.Equal(t, Checksum([]byte("tx0")), tx.Hash())
+	require.Equal(t, []byte("tx0"), tx.Bytes())
+	require.Equal(t, "A", tx.Label())
+
+	custom := NewRawTx([]byte("tx0"), func([]byte) Hash { return Hash{1} }, "")
+	require.Equal(t, Hash{1}, custom.Hash())
+
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes()})
+	require.True(t, w.AddTx(tx))
+	_, err := w.AddVote(NewVote(pub0, 0, tx))
+	require.NoError(t, err)
+	require.False(t, w.IsBlocked(tx))
+}
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"crypto/ed25519"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"runtime"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -16,15 +20,51 @@ var (
 	// Quorum defines the ratio of neccesary votes to consider something valid.
 	// Changing this is uncommon but it might be required on some blockchains
 	// or for testing purposes.
+	// Quorum is the default value, see WithQuorumFraction to set it per
+	// instance.
 	Quorum = float64(2) / 3
 )
 
+// ErrInvalidHashLength is returned when a hash doesn't have HashLen bytes.
+var ErrInvalidHashLength = errors.New("invalid hash length")
+
+// The following errors are returned by SignedVote.VerifyE and
+// SignedVote.VerifyTx.
+var (
+	ErrBadSignature   = fmt.Errorf("%w: bad signature", ErrInvalidSignature)
+	ErrPubkeyLen      = fmt.Errorf("%w: invalid pubkey length", ErrInvalidSignature)
+	ErrTxHashMismatch = errors.New("tx hash mismatch")
+)
+
 type Hash [HashLen]byte
 
+// HashFromBytes turns bz into a Hash, it returns an error if the length of bz
+// is not HashLen, so that hashes are never silently truncated or padded.
+func HashFromBytes(bz []byte) (Hash, error) {
+	var hash Hash
+	if len(bz) != HashLen {
+		return hash, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidHashLength, HashLen, len(bz))
+	}
+	copy(hash[:], bz)
+	return hash, nil
+}
+
 func (h Hash) String() string { return string(h[:]) }
 
+// sortHashes sorts a list of hashes in ascending order.
+func sortHashes(hashes []Hash) {
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+}
+
 type ID string
 
+// Tx is the transaction type Wendy orders.
+// Label is the partition key of the tx (e.g a market): fairness is kept
+// independently for every label, peers keep their votes per label, and txs
+// with different labels never block each other, so a single Wendy instance
+// and validator set can order several independent streams.
 type Tx interface {
 	Bytes() []byte
 	Hash() Hash
@@ -44,17 +84,96 @@ func (set BlockingSet) String() string {
 // Block holds a list of Tx.
 type Block struct {
 	Txs []Tx
+
+	// Cyclic holds the hashes of the txs that are part of a fairness loop,
+	// i.e txs that have been forced together because they can't be ordered.
+	// Cyclic is nil when the block has no fairness loops.
+	Cyclic map[Hash]bool
+
+	// Forced holds the hashes of the txs that have been included without
+	// their blocking txs because they were unblocked for longer than the
+	// fairness timeout (see WithFairnessTimeout).
+	// Forced is nil when no tx has been forced.
+	Forced map[Hash]bool
+
+	// IncludeBytes determines whether the txs bytes are included when the
+	// block is encoded as JSON, see MarshalJSON.
+	IncludeBytes bool
+}
+
+// Hash returns the sha256 hash of the concatenation of the block's tx hashes.
+// The hash depends on the order of the txs, blocks produced by NewBlock have
+// their txs sorted by hash, so the same set of txs produces the same hash.
+func (b Block) Hash() Hash {
+	buf := make([]byte, 0, len(b.Txs)*HashLen)
+	for _, tx := range b.Txs {
+		hash := tx.Hash()
+		buf = append(buf, hash[:]...)
+	}
+	return Checksum(buf)
+}
+
+// Equal returns true if both blocks have the same txs in the same order.
+// Txs are compared by their hash.
+func (b Block) Equal(other Block) bool {
+	if len(b.Txs) != len(other.Txs) {
+		return false
+	}
+	for i, tx := range b.Txs {
+		if tx.Hash() != other.Txs[i].Hash() {
+			return false
+		}
+	}
+	return true
 }
 
 // Validators are identified by their public key.
 type Validator Pubkey
 
+// ValidatorSetDiff holds the changes between two validator sets.
+type ValidatorSetDiff struct {
+	Added   []Validator
+	Removed []Validator
+}
+
+// diffValidators returns the validators present in next but not in prev
+// (Added) and the ones present in prev but not in next (Removed).
+func diffValidators(prev, next []Validator) ValidatorSetDiff {
+	index := func(vs []Validator) map[ID]struct{} {
+		m := make(map[ID]struct{}, len(vs))
+		for _, v := range vs {
+			m[ID(Pubkey(v).String())] = struct{}{}
+		}
+		return m
+	}
+	prevIDs, nextIDs := index(prev), index(next)
+
+	var diff ValidatorSetDiff
+	for _, v := range next {
+		if _, ok := prevIDs[ID(Pubkey(v).String())]; !ok {
+			diff.Added = append(diff.Added, v)
+		}
+	}
+	for _, v := range prev {
+		if _, ok := nextIDs[ID(Pubkey(v).String())]; !ok {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+	return diff
+}
+
 type Vote struct {
 	Pubkey Pubkey
 
 	// Label is used for bucketing, it can be empty
 	Label string
 
+	// ReceivedAt is the local time at which the vote was added to Wendy, it
+	// is set by AddVote and it's not part of the digest.
+	// Since every node receives votes at different times, ReceivedAt is not
+	// consensus critical and should only be used as a local hint.
+	ReceivedAt time.Time
+
 	// The following fields are used to produce the digest.
 	// NOTE: it's hard to keep in sync these fields and the one used in
 	// `digest()`.
@@ -114,22 +233,116 @@ func (v *Vote) Key() ID {
 	return ID(v.Pubkey.String())
 }
 
+// GossipID returns a key identifying the vote among the votes gossiped by its
+// peer, made of its pubkey, seq and label, so that it's stable across
+// serialization round-trips (see SignedVote.Marshal).
+// It can be used to track which votes a peer already has. Equivocating votes
+// (same seq for different txs) share the same GossipID.
+func (v *Vote) GossipID() string {
+	return fmt.Sprintf("%s/%d/%s", v.Pubkey, v.Seq, v.Label)
+}
+
 // SignedVote wraps a vote with its signature.
+// Scheme is the signature scheme used to sign the vote, see Signer.
 type SignedVote struct {
+	Scheme    Scheme
 	Signature []byte
 	Data      *Vote
 }
 
-// NewSignedVote signs a vote and return it wrapped inside a SignedVote.
+// NewSignedVote signs a vote using ed25519 and return it wrapped inside a
+// SignedVote, see SignVote for other signature schemes.
 func NewSignedVote(key ed25519.PrivateKey, v *Vote) *SignedVote {
 	return &SignedVote{
+		Scheme:    Ed25519,
 		Signature: ed25519.Sign(key, v.digest()),
 		Data:      v,
 	}
 }
 
+// SignVote signs a vote with signer and returns it wrapped inside a
+// SignedVote along with the signer's scheme.
+func SignVote(signer Signer, v *Vote) (*SignedVote, error) {
+	sig, err := signer.Sign(v.digest())
+	if err != nil {
+		return nil, err
+	}
+	return &SignedVote{
+		Scheme:    signer.Scheme(),
+		Signature: sig,
+		Data:      v,
+	}, nil
+}
+
 // Verify verifies the signature from SignedVote given the vote's pubkey.
+// See VerifyE to find out why the verification failed.
 func (sv *SignedVote) Verify() bool {
-	pub := ed25519.PublicKey(sv.Data.Pubkey)
-	return ed25519.Verify(pub, sv.Data.digest(), sv.Signature)
+	return sv.VerifyE() == nil
+}
+
+// GossipID returns the GossipID of the signed vote, see Vote.GossipID.
+func (sv *SignedVote) GossipID() string {
+	return sv.Data.GossipID()
+}
+
+// VerifyE is like Verify but it returns the reason why the verification
+// failed, the signature is verified by the Verifier registered for the
+// vote's scheme (see RegisterVerifier), or ErrUnknownScheme is returned if
+// there is none. For ed25519, it returns ErrPubkeyLen if the vote's pubkey is
+// not a valid ed25519 public key or ErrBadSignature if the signature doesn't
+// match.
+// All of the errors wrap ErrInvalidSignature.
+func (sv *SignedVote) VerifyE() error {
+	v, ok := verifierFor(sv.Scheme)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownScheme, sv.Scheme)
+	}
+	return v.Verify(sv.Data.Pubkey, sv.Data.digest(), sv.Signature)
+}
+
+// VerifyTx verifies the signature (see VerifyE) and that the vote is for tx,
+// otherwise it returns ErrTxHashMismatch.
+func (sv *SignedVote) VerifyTx(tx Tx) error {
+	if err := sv.VerifyE(); err != nil {
+		return err
+	}
+	if sv.Data.TxHash != tx.Hash() {
+		return fmt.Errorf("%w: expected %s, got %s", ErrTxHashMismatch, shortHash(tx.Hash()), shortHash(sv.Data.TxHash))
+	}
+	return nil
+}
+
+// VerifyBatch verifies the signed votes (see SignedVote.VerifyE) in parallel
+// using up to GOMAXPROCS workers.
+// It returns the verification error of every vote, aligned by index with
+// votes, a nil error means the vote is valid.
+func VerifyBatch(votes []*SignedVote) []error {
+	errs := make([]error, len(votes))
+	parallelFor(len(votes), func(i int) {
+		errs[i] = votes[i].VerifyE()
+	})
+	return errs
+}
+
+// parallelFor calls fn for every index in [0, n) using up to GOMAXPROCS
+// goroutines and returns once all the calls are done.
+// Every goroutine handles a disjoint set of indexes, so that fn can write to
+// a slice by index without locking.
+func parallelFor(n int, fn func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for ; i < n; i += workers {
+				fn(i)
+			}
+		}(i)
+	}
+	wg.Wait()
 }
@@ -1,11 +1,13 @@
 package wendy
 
 import (
+	"bytes"
 	"crypto/ed25519"
 	"testing"
 
 	"github.com/sebdah/goldie/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 /*
@@ -50,6 +52,32 @@ var (
 // Note that Pubkey (pub0) matches the one used on testVote<N> on purpose
 var newTestPeer = func() *Peer { return NewPeer(pub0) }
 
+// newTestWendy returns a Wendy instance where each pubkey is a validator
+// that votes for its txs in order.
+func newTestWendy(t *testing.T, votes map[*Pubkey][]Tx) *Wendy {
+	w := New()
+
+	var vs []Validator
+	for pub := range votes {
+		vs = append(vs, pub.Bytes())
+	}
+	w.UpdateValidatorSet(vs)
+
+	for pub, txs := range votes {
+		var lastAddedVote *Vote
+		for i, tx := range txs {
+			vote := NewVote(*pub, uint64(i), tx)
+			if lastAddedVote != nil {
+				vote.WithPrevHash(lastAddedVote.Hash())
+			}
+			require.NoError(t, w.AddVotes(vote))
+			lastAddedVote = vote
+			w.AddTx(tx)
+		}
+	}
+	return w
+}
+
 // TestVotes uses golden files via goldie package.  In order to update
 // the golden files, run the tests using -update flag, i.e: go test -update
 // This test ensures that the hashing function or digest function did not change.
@@ -76,3 +104,50 @@ func TestVotes(t *testing.T) {
 		assert.Equal(t, testVote3.Hash(), testVote4.PrevHash)
 	})
 }
+
+func TestVoteGossipID(t *testing.T) {
+	tx := NewSimpleTx("tx0", "h0")
+	vote := NewVote(pub0, 1, tx)
+
+	// the time is not part of the id.
+	assert.Equal(t, vote.GossipID(), NewVote(pub0, 1, tx).GossipID())
+
+	for _, other := range []*Vote{
+		NewVote(pub1, 1, tx),
+		NewVote(pub0, 2, tx),
+		NewVote(pub0, 1, NewSimpleTx("tx0", "h0").withLabel("label")),
+	} {
+		assert.NotEqual(t, vote.GossipID(), other.GossipID())
+	}
+}
+
+func TestHashFromBytes(t *testing.T) {
+	bz := bytes.Repeat([]byte{0xab}, HashLen)
+	hash, err := HashFromBytes(bz)
+	require.NoError(t, err)
+	assert.Equal(t, bz, hash[:])
+
+	for _, l := range []int{0, HashLen - 1, HashLen + 1} {
+		_, err := HashFromBytes(make([]byte, l))
+		assert.ErrorIs(t, err, ErrInvalidHashLength, "length %d", l)
+	}
+}
+
+func TestBlockHash(t *testing.T) {
+	b1 := Block{Txs: []Tx{testTx0, testTx1, testTx2}}
+	b2 := Block{Txs: []Tx{
+		&decodedTx{bytes: testTx0.Bytes(), hash: testTx0.Hash()},
+		&decodedTx{bytes: testTx1.Bytes(), hash: testTx1.Hash()},
+		&decodedTx{bytes: testTx2.Bytes(), hash: testTx2.Hash()},
+	}}
+	assert.True(t, b1.Equal(b2))
+	assert.Equal(t, b1.Hash(), b2.Hash())
+
+	reordered := Block{Txs: []Tx{testTx1, testTx0, testTx2}}
+	assert.False(t, b1.Equal(reordered))
+	assert.NotEqual(t, b1.Hash(), reordered.Hash())
+
+	shorter := Block{Txs: []Tx{testTx0, testTx1}}
+	assert.False(t, b1.Equal(shorter))
+	assert.NotEqual(t, b1.Hash(), shorter.Hash())
+}
@@ -0,0 +1,164 @@
+package wendy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrDuplicateValidator = errors.New("duplicate validator")
+	ErrEmptyValidator     = errors.New("empty validator")
+	ErrTooFewValidators   = errors.New("too few validators")
+	ErrInvalidValidatorID = errors.New("invalid validator ID")
+)
+
+// ValidatorSet is a validator set that can be shared by several Wendy
+// instances (e.g one per shard or market), so that updating it updates all
+// the bound instances, see Wendy.BindValidatorSet.
+// ValidatorSet is safe for concurrent access.
+type ValidatorSet struct {
+	mtx        sync.Mutex
+	validators []Validator
+	weights    map[ID]uint64
+	bound      []*Wendy
+}
+
+// NewValidatorSet returns a new empty ValidatorSet.
+func NewValidatorSet() *ValidatorSet {
+	return &ValidatorSet{
+		weights: make(map[ID]uint64),
+	}
+}
+
+// Update updates the validator set of all the bound instances, every
+// validator is given a voting weight of 1 (see Wendy.UpdateValidatorSet).
+func (vs *ValidatorSet) Update(validators []Validator) {
+	validators = uniqueValidators(validators)
+	vs.update(validators, unitWeights(validators))
+}
+
+// UpdateWeighted updates the validator set of all the bound instances along
+// with the validators' voting weight (see Wendy.UpdateValidatorSetWeighted).
+// It returns an error without updating the validator set if any of the IDs is
+// invalid.
+func (vs *ValidatorSet) UpdateWeighted(weights map[ID]uint64) error {
+	validators, weights, err := weightedValidators(weights)
+	if err != nil {
+		return err
+	}
+	vs.update(validators, weights)
+	return nil
+}
+
+func (vs *ValidatorSet) update(validators []Validator, weights map[ID]uint64) {
+	vs.mtx.Lock()
+	defer vs.mtx.Unlock()
+
+	vs.validators, vs.weights = validators, weights
+	for _, w := range vs.bound {
+		w.updateValidatorSet(validators, weights)
+	}
+}
+
+// BindValidatorSet binds the Wendy instance to a shared ValidatorSet, from
+// then on, every update of vs is applied to the instance.
+// The current validators of vs, if any, are applied right away.
+// Instances can still be updated individually via UpdateValidatorSet, which
+// will make them drift from the shared set until the next update.
+func (w *Wendy) BindValidatorSet(vs *ValidatorSet) {
+	vs.mtx.Lock()
+	defer vs.mtx.Unlock()
+
+	vs.bound = append(vs.bound, w)
+	if len(vs.validators) > 0 {
+		w.updateValidatorSet(vs.validators, vs.weights)
+	}
+}
+
+// ValidateValidators returns an error if any of the validators is empty
+// (ErrEmptyValidator) or present more than once (ErrDuplicateValidator).
+func ValidateValidators(vs []Validator) error {
+	seen := make(map[ID]struct{}, len(vs))
+	for i, v := range vs {
+		if len(v) == 0 {
+			return fmt.Errorf("%w: index %d", ErrEmptyValidator, i)
+		}
+		id := ID(Pubkey(v).String())
+		if _, ok := seen[id]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateValidator, id)
+		}
+		seen[id] = struct{}{}
+	}
+	return nil
+}
+
+// uniqueValidators returns the validators without the empty and duplicated
+// ones, only the first occurrence of a validator is kept.
+func uniqueValidators(vs []Validator) []Validator {
+	seen := make(map[ID]struct{}, len(vs))
+	unique := make([]Validator, 0, len(vs))
+	for _, v := range vs {
+		if len(v) == 0 {
+			continue
+		}
+		id := ID(Pubkey(v).String())
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// unitWeights returns the weights of the validators when all of them have a
+// voting weight of 1.
+func unitWeights(vs []Validator) map[ID]uint64 {
+	weights := make(map[ID]uint64, len(vs))
+	for _, val := range vs {
+		weights[ID(Pubkey(val).String())] = 1
+	}
+	return weights
+}
+
+// weightedValidators returns the validators identified by the weights' IDs
+// sorted, so that the validator set is deterministic, along with the weights
+// keyed by the canonical ID of every validator (see Pubkey.String), since
+// peers are looked up by it, e.g "AB" and "0xab" identify the same validator.
+// It returns an error wrapping ErrInvalidValidatorID if an ID is not hex
+// encoded, ErrEmptyValidator if it's empty or ErrDuplicateValidator if two
+// IDs identify the same validator.
+func weightedValidators(weights map[ID]uint64) ([]Validator, map[ID]uint64, error) {
+	ids := make([]string, 0, len(weights))
+	for id := range weights {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	var (
+		validators = make([]Validator, 0, len(ids))
+		canonical  = make(map[ID]uint64, len(ids))
+	)
+	for _, id := range ids {
+		pub, err := parsePubkey(ID(id))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %q: %v", ErrInvalidValidatorID, id, err)
+		}
+		if len(pub) == 0 {
+			return nil, nil, fmt.Errorf("%w: %q", ErrEmptyValidator, id)
+		}
+
+		key := ID(pub.String())
+		if _, ok := canonical[key]; ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrDuplicateValidator, key)
+		}
+		canonical[key] = weights[ID(id)]
+		validators = append(validators, Validator(pub))
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return Pubkey(validators[i]).String() < Pubkey(validators[j]).String()
+	})
+	return validators, canonical, nil
+}
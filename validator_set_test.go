@@ -0,0 +1,38 @@
+package wendy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorSet(t *testing.T) {
+	vs := NewValidatorSet()
+
+	w1, w2 := New(), New()
+	w1.BindValidatorSet(vs)
+	w2.BindValidatorSet(vs)
+	assert.Equal(t, 0, w1.ValidatorCount())
+
+	vs.Update([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes()})
+	for _, w := range []*Wendy{w1, w2} {
+		assert.Equal(t, 4, w.ValidatorCount())
+		assert.Equal(t, 3, w.Quorum())
+	}
+
+	vs.UpdateWeighted(map[ID]uint64{
+		ID(pub0.String()): 5,
+		ID(pub1.String()): 1,
+	})
+	for _, w := range []*Wendy{w1, w2} {
+		assert.Equal(t, 2, w.ValidatorCount())
+		assert.Equal(t, 5, w.Quorum())
+	}
+
+	t.Run("BindAfterUpdate", func(t *testing.T) {
+		w := New()
+		w.BindValidatorSet(vs)
+		assert.Equal(t, 2, w.ValidatorCount())
+		assert.Equal(t, 5, w.Quorum())
+	})
+}
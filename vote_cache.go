@@ -0,0 +1,74 @@
+package wendy
+
+import (
+	"container/list"
+	"sync"
+)
+
+// voteCache is a LRU of the signed votes recently added, see WithVoteCache.
+// It is safe for concurrent access.
+type voteCache struct {
+	mtx   sync.Mutex
+	size  int
+	order *list.List // order holds the keys, most recently used first.
+	keys  map[Hash]*list.Element
+}
+
+func newVoteCache(size int) *voteCache {
+	return &voteCache{
+		size:  size,
+		order: list.New(),
+		keys:  make(map[Hash]*list.Element),
+	}
+}
+
+// signedVoteKey identifies a signed vote by its vote, scheme and signature,
+// so that a forged signature is never taken as an already verified vote.
+func signedVoteKey(sv *SignedVote) Hash {
+	buf := append([]byte{byte(sv.Scheme)}, sv.Data.digest()...)
+	return Checksum(append(buf, sv.Signature...))
+}
+
+// reset removes all the keys, a nil cache is a no-op.
+func (c *voteCache) reset() {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.order.Init()
+	c.keys = make(map[Hash]*list.Element)
+}
+
+// seen returns true if the key has been added, marking it as recently used.
+func (c *voteCache) seen(key Hash) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.keys[key]
+	if ok {
+		c.order.MoveToFront(e)
+	}
+	return ok
+}
+
+// add adds the key evicting the least recently used one if the cache is
+// full.
+func (c *voteCache) add(key Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.keys[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.keys[key] = c.order.PushFront(key)
+	if c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.keys, last.Value.(Hash))
+	}
+}
@@ -0,0 +1,51 @@
+package wendy
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteCache(t *testing.T) {
+	c := newVoteCache(2)
+	c.add(testTx0.Hash())
+	c.add(testTx1.Hash())
+	require.True(t, c.seen(testTx0.Hash()))
+
+	// testTx1 is the least recently used.
+	c.add(testTx2.Hash())
+	assert.True(t, c.seen(testTx0.Hash()))
+	assert.False(t, c.seen(testTx1.Hash()))
+	assert.True(t, c.seen(testTx2.Hash()))
+
+	c.reset()
+	assert.False(t, c.seen(testTx0.Hash()))
+}
+
+func TestAddSignedVoteWithVoteCache(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+	w := New(WithVoteCache(10))
+
+	sv := NewSignedVote(priv, NewVote(Pubkey(pub), 0, testTx0))
+	added, err := w.AddSignedVote(sv)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// the cached vote is discarded before verifying it.
+	require.True(t, w.voteCache.seen(signedVoteKey(sv)))
+	added, err = w.AddSignedVote(sv)
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	// the same vote with a forged signature is still verified.
+	forged := &SignedVote{Data: sv.Data, Signature: make([]byte, ed25519.SignatureSize)}
+	_, err = w.AddSignedVote(forged)
+	assert.ErrorIs(t, err, ErrBadSignature)
+
+	// invalid votes are not cached.
+	_, err = w.AddSignedVote(forged)
+	assert.ErrorIs(t, err, ErrBadSignature)
+}
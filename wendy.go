@@ -1,200 +1,1718 @@
 package wendy
 
 import (
+	"container/heap"
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"sort"
 	"sync"
+	"time"
+
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// The following errors are returned by AddTxE.
+var (
+	ErrTxAlreadyAdded = errors.New("tx already added")
+	ErrMaxPendingTxs  = errors.New("max pending txs reached")
 )
 
+// ErrUnknownTx is returned by ValidateBlock when a block holds a tx that is
+// not pending.
+var ErrUnknownTx = errors.New("unknown tx")
+
 // Wendy is the root of the Wendy fairness implementation. It holds a set of
 // peers and acts as a proxy to them. Wendy keeps track of all Peers's state
 // and aggregates them in order to do vote counting.
 //
 // Invoking Wendy methods is thread safe.
 type Wendy struct {
+	quorumFraction float64
+	seqWindow      uint64        // seqWindow is set on every peer, see WithSequenceWindow.
+	maxPendingTxs  int           // maxPendingTxs limits the txs held, see WithMaxPendingTxs.
+	stallTimeout   time.Duration // stallTimeout is used by IsStalled, see WithStallTimeout.
+	minValidators  int           // minValidators halts Wendy below it, see WithMinValidators.
+	graceful       bool          // graceful enables the validator set transitions, see WithGracefulTransition.
+	rand           io.Reader     // rand is the source of randomness of the Random tie-break, see WithRand.
+
+	// admissionPolicy is called before adding a tx, see WithAdmissionPolicy.
+	admissionPolicy func(Tx) error
+
+	// fairnessTimeout relaxes fairness for the txs unblocked for longer, see
+	// WithFairnessTimeout.
+	fairnessTimeout time.Duration
+
+	// the validator set is guarded by peersMtx, since the peers are updated
+	// along with it, see setValidatorSet.
 	validators []Validator
-	quorum     int // quorum gets updated every time the validator set is updated.
+	weights    map[ID]uint64 // weights holds the voting power of each validator.
+	weight     uint64        // weight is the sum of all the validators' weights.
+	quorum     uint64        // quorum gets updated every time the validator set is updated.
 
-	txsMtx sync.RWMutex
-	txs    *Txs
+	txsMtx    sync.RWMutex
+	txs       *Txs
+	deadlines map[Hash]time.Time // deadlines holds the txs added via AddTxWithDeadline.
+	deps      map[Hash][]Hash    // deps holds the declared predecessors of the txs added via AddTxWithDeps.
+	rounds    map[Hash]int64     // rounds holds the txs added via AddTxWithRound.
+	pendingAt time.Time          // pendingAt is the last time a tx was added while there were none pending.
+	history   *history           // history holds the last committed blocks, see WithHistory.
 
 	peersMtx sync.RWMutex
 	votes    map[Hash]*Vote
 	peers    map[ID]*Peer
+	peerIDs  []ID               // peerIDs holds the keys of peers sorted, see setPeers.
+	stale    map[ID]struct{}    // stale holds the peers whose votes are not counted, see ExpireStalePeers.
+	arrivals map[Hash]time.Time // arrivals holds the earliest ReceivedAt of the votes for each tx.
+	votedAt  time.Time          // votedAt is the last time a vote was added.
+
+	// eligibleAt holds the first time every pending tx was seen unblocked,
+	// it is only tracked when WithFairnessTimeout is set.
+	eligibleAt map[Hash]time.Time
+
+	// duplicateVotes counts the votes that were already added, see
+	// DuplicateVoteCount.
+	duplicateVotes uint64
+
+	// signatures holds the votes added via AddSignedVote by tx hash and peer,
+	// see Certificate.
+	signatures map[Hash]map[ID]*SignedVote
+
+	// evidence holds the first equivocation of every peer for which both
+	// signed votes are known, see EvidenceFor.
+	evidence map[ID]*Evidence
+
+	// voteCache holds the signed votes recently added, see WithVoteCache.
+	voteCache *voteCache
+
+	// transition holds the previous validator set, see WithGracefulTransition.
+	transition *transition
+
+	// cache is used by BlockingSetCached, it is nil until the first call or
+	// after being invalidated.
+	cacheMtx sync.Mutex
+	cache    *blockingSetCache
+
+	metrics *metrics
+	logger  log.Logger
+	auditor func(AuditEvent) // auditor is guarded by peersMtx, see SetAuditor.
+
+	// onUnblock holds the callbacks registered via OnUnblock, it is guarded
+	// by peersMtx.
+	onUnblock []func(Tx)
+
+	// blockingSubs holds the subscribers of SubscribeBlockingChanges, it is
+	// guarded by peersMtx.
+	blockingSubs []*blockingSubscription
+
+	// newVotes is closed (and replaced) every time a vote is added, it is
+	// used to wake up the routines waiting on WaitUntilUnblocked.
+	newVotes chan struct{}
 }
 
-// New returns a new Wendy instance.
+// New returns a new Wendy instance configured with the given options.
 // Normally a mempool should hold only one instance.
-func New() *Wendy {
-	return &Wendy{
-		txs:   NewTxs(),
-		votes: make(map[Hash]*Vote),
-		peers: make(map[ID]*Peer),
+func New(opts ...Option) *Wendy {
+	w := &Wendy{
+		quorumFraction: Quorum,
+		stallTimeout:   DefaultStallTimeout,
+		rand:           crand.Reader,
+		txs:            NewTxs(),
+		deadlines:      make(map[Hash]time.Time),
+		deps:           make(map[Hash][]Hash),
+		rounds:         make(map[Hash]int64),
+		votes:          make(map[Hash]*Vote),
+		peers:          make(map[ID]*Peer),
+		stale:          make(map[ID]struct{}),
+		arrivals:       make(map[Hash]time.Time),
+		eligibleAt:     make(map[Hash]time.Time),
+		signatures:     make(map[Hash]map[ID]*SignedVote),
+		evidence:       make(map[ID]*Evidence),
+		newVotes:       make(chan struct{}),
+		logger:         log.NewNopLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(w)
 	}
+	return w
+}
+
+// SetLogger sets the logger used to trace Wendy's internal decisions.
+// By default Wendy does not log.
+func (w *Wendy) SetLogger(l log.Logger) {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.logger = l
 }
 
 // UpdateValidatorSet updates the list of validators in the consensus.
 // Updating the validator set might affect the value of the Quorum field.
 // Upon updating the peers that are not in the new validator set are removed.
-func (w *Wendy) UpdateValidatorSet(vs []Validator) {
-	w.validators = vs
+// Every validator is given a voting weight of 1, see
+// UpdateValidatorSetWeighted for stake-weighted validator sets.
+// Empty and duplicated validators are ignored, so that they don't count
+// towards the quorum, see UpdateValidatorSetStrict to reject them instead.
+// It returns the validators added and removed compared to the previous set.
+func (w *Wendy) UpdateValidatorSet(vs []Validator) ValidatorSetDiff {
+	vs = uniqueValidators(vs)
+	return w.updateValidatorSet(vs, unitWeights(vs))
+}
+
+// UpdateValidatorSetStrict is like UpdateValidatorSet, but it returns an error
+// (see ValidateValidators) without updating the validator set if any of the
+// validators is empty or duplicated, or ErrTooFewValidators if there are
+// fewer validators than required (see WithMinValidators).
+func (w *Wendy) UpdateValidatorSetStrict(vs []Validator) (ValidatorSetDiff, error) {
+	if err := ValidateValidators(vs); err != nil {
+		return ValidatorSetDiff{}, err
+	}
+	if min := w.minValidators; len(vs) < min {
+		return ValidatorSetDiff{}, fmt.Errorf("%w: expected at least %d, got %d", ErrTooFewValidators, min, len(vs))
+	}
+	return w.updateValidatorSet(vs, unitWeights(vs)), nil
+}
 
-	q := math.Floor(
-		float64(len(vs))*Quorum,
-	) + 1
-	w.quorum = int(q)
+// UpdateValidatorSetWeighted updates the list of validators in the consensus
+// along with their voting power (i.e their stake).
+// The validators are identified by their ID (see Pubkey.String()).
+// The quorum is computed as the smallest sum of weights exceeding the Quorum
+// ratio of the total weight.
+// IDs are hex encoded, with or without the 0x prefix and in any case. If any
+// of them is not hex encoded (ErrInvalidValidatorID), empty
+// (ErrEmptyValidator) or identifies the same validator as another one
+// (ErrDuplicateValidator), an error is returned without updating the
+// validator set.
+// It returns the validators added and removed compared to the previous set,
+// validators whose weight has changed are not part of the diff.
+func (w *Wendy) UpdateValidatorSetWeighted(vs map[ID]uint64) (ValidatorSetDiff, error) {
+	validators, weights, err := weightedValidators(vs)
+	if err != nil {
+		return ValidatorSetDiff{}, err
+	}
+	return w.updateValidatorSet(validators, weights), nil
+}
 
+func (w *Wendy) updateValidatorSet(vs []Validator, weights map[ID]uint64) ValidatorSetDiff {
 	w.peersMtx.Lock()
 	defer w.peersMtx.Unlock()
+
+	diff := diffValidators(w.validators, vs)
+	w.setValidatorSet(vs, weights)
+	return diff
+}
+
+// setValidatorSet updates the validator set, the quorum and the peers.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) setValidatorSet(vs []Validator, weights map[ID]uint64) {
+	w.startTransition()
+
+	w.validators = vs
+	w.weights = weights
+
+	var total uint64
+	for _, weight := range weights {
+		total += weight
+	}
+	w.weight = total
+
+	// quorum can't be reached without validators, see quorumReached.
+	var q float64
+	if total > 0 {
+		q = math.Floor(
+			float64(total)*w.quorumFraction,
+		) + 1
+	}
+	w.logger.Debug("Updating validator set", "validators", len(vs), "old_quorum", w.quorum, "new_quorum", uint64(q))
+	w.quorum = uint64(q)
+
 	peers := make(map[ID]*Peer)
 	// keep all the peers we already have and create new one if not present
 	// those old peers that are not part of the new set will be discarded.
 	for _, val := range vs {
 		key := Pubkey(val)
 		id := ID(key.String())
-		if s, ok := w.peers[id]; ok {
-			peers[id] = s
-		} else {
-			peers[id] = NewPeer(key)
+		peer, ok := w.peers[id]
+		if !ok {
+			peer = w.newPeer(key)
+		}
+		peer.setWeight(weights[id])
+		peers[id] = peer
+	}
+	w.setPeers(peers)
+	w.invalidateBlockingSet()
+
+	w.metrics.setPeers(len(w.peers))
+	w.metrics.setQuorum(w.quorum)
+}
+
+// setPeers sets the peers along with their sorted IDs, so that they can be
+// iterated in the same order on every node, see quorumReached.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) setPeers(peers map[ID]*Peer) {
+	ids := make([]ID, 0, len(peers))
+	for id := range peers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	w.peers, w.peerIDs = peers, ids
+}
+
+// newPeer returns a new Peer configured with Wendy's options.
+func (w *Wendy) newPeer(pub Pubkey) *Peer {
+	peer := NewPeer(pub)
+	peer.SetSequenceWindow(w.seqWindow)
+	return peer
+}
+
+// Reset clears all of Wendy's state, i.e the txs, the votes, the history (see
+// WithHistory) and the state of every peer, including the ones that are not
+// validators, as if Wendy had just been created. The validator set, along with the weights and the
+// quorum, and the options and callbacks are kept.
+func (w *Wendy) Reset() {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.txs = NewTxs()
+	w.deadlines = make(map[Hash]time.Time)
+	w.deps = make(map[Hash][]Hash)
+	w.rounds = make(map[Hash]int64)
+	if w.history != nil {
+		w.history = newHistory(w.history.size)
+	}
+
+	w.votes = make(map[Hash]*Vote)
+	w.stale = make(map[ID]struct{})
+	w.arrivals = make(map[Hash]time.Time)
+	w.eligibleAt = make(map[Hash]time.Time)
+	w.signatures = make(map[Hash]map[ID]*SignedVote)
+	w.evidence = make(map[ID]*Evidence)
+	w.duplicateVotes = 0
+	w.transition = nil
+	w.voteCache.reset()
+
+	peers := make(map[ID]*Peer)
+	for _, val := range w.validators {
+		pub := Pubkey(val)
+		id := ID(pub.String())
+		peer := w.newPeer(pub)
+		peer.setWeight(w.weights[id])
+		peers[id] = peer
+	}
+	w.setPeers(peers)
+	w.invalidateBlockingSet()
+	w.metrics.setPeers(len(w.peers))
+	w.logger.Debug("Resetting state")
+}
+
+// AddValidator adds a validator with a voting weight of 1 to the current
+// validator set, the votes of the other validators are preserved.
+// It returns false if the validator is already present.
+func (w *Wendy) AddValidator(v Validator) bool {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	id := ID(Pubkey(v).String())
+	if _, ok := w.weights[id]; ok {
+		return false
+	}
+
+	vs := make([]Validator, 0, len(w.validators)+1)
+	vs = append(vs, w.validators...)
+	vs = append(vs, v)
+
+	weights := w.copyWeights()
+	weights[id] = 1
+
+	w.setValidatorSet(vs, weights)
+	return true
+}
+
+// RemoveValidator removes a validator from the current validator set, the
+// votes of the other validators are preserved.
+// It returns false if the validator is not present.
+func (w *Wendy) RemoveValidator(v Validator) bool {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	id := ID(Pubkey(v).String())
+	if _, ok := w.weights[id]; !ok {
+		return false
+	}
+
+	vs := make([]Validator, 0, len(w.validators))
+	for _, val := range w.validators {
+		if ID(Pubkey(val).String()) != id {
+			vs = append(vs, val)
+		}
+	}
+
+	weights := w.copyWeights()
+	delete(weights, id)
+
+	w.setValidatorSet(vs, weights)
+	return true
+}
+
+// RekeyValidator replaces the key of a validator, keeping its voting weight
+// and its votes, which are migrated to the new key.
+// Signed votes from the old key are not valid for the new one, hence they
+// can't be part of a Certificate anymore.
+// It returns false if old is not a validator or new is already known, either
+// as a validator or as a peer.
+func (w *Wendy) RekeyValidator(old, new Validator) bool {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	oldID, newID := ID(Pubkey(old).String()), ID(Pubkey(new).String())
+	weight, ok := w.weights[oldID]
+	if !ok {
+		return false
+	}
+	if _, ok := w.weights[newID]; ok {
+		return false
+	}
+	if _, ok := w.peers[newID]; ok {
+		return false
+	}
+
+	vs := make([]Validator, 0, len(w.validators))
+	for _, val := range w.validators {
+		if ID(Pubkey(val).String()) == oldID {
+			val = new
+		}
+		vs = append(vs, val)
+	}
+	weights := w.copyWeights()
+	delete(weights, oldID)
+	weights[newID] = weight
+	w.validators, w.weights = vs, weights
+
+	if peer, ok := w.peers[oldID]; ok {
+		delete(w.peers, oldID)
+		w.peers[newID] = peer
+		w.setPeers(w.peers)
+		for _, v := range peer.rekey(Pubkey(new)) {
+			if cur, ok := w.votes[v.TxHash]; ok && cur.Key() == oldID {
+				w.votes[v.TxHash] = v
+			}
+		}
+	}
+	if _, ok := w.stale[oldID]; ok {
+		delete(w.stale, oldID)
+		w.stale[newID] = struct{}{}
+	}
+	if t := w.transition; t != nil {
+		if peer, ok := t.peers[oldID]; ok {
+			t.peers[newID], t.weights[newID] = peer, t.weights[oldID]
+			delete(t.peers, oldID)
+			delete(t.weights, oldID)
+		}
+	}
+	for _, signed := range w.signatures {
+		delete(signed, oldID)
+	}
+
+	w.logger.Debug("Rekeying validator", "old", oldID, "new", newID)
+	return true
+}
+
+// copyWeights returns a copy of the validators' weights.
+// The caller must hold the peersMtx lock.
+func (w *Wendy) copyWeights() map[ID]uint64 {
+	weights := make(map[ID]uint64, len(w.weights))
+	for id, weight := range w.weights {
+		weights[id] = weight
+	}
+	return weights
+}
+
+// Quorum returns the voting weight required to reach quorum. It gets updated
+// every time the validator set is updated.
+// When all the validators have the same weight of 1, this is the number of
+// votes.
+func (w *Wendy) Quorum() int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+	return int(w.quorum)
+}
+
+// ValidatorCount returns the number of validators in the current validator
+// set.
+func (w *Wendy) ValidatorCount() int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+	return len(w.validators)
+}
+
+// HonestParties returns the required voting weight to be sure that at least
+// one vote came from a honest validator.
+// t + 1
+// When all the validators have the same weight of 1, this is the number of
+// votes.
+// It returns 0 when the validator set is empty.
+func (w *Wendy) HonestParties() int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return int(w.quorum)
+}
+
+// HonestMajority returns the minimum voting weight required to assure that I
+// have a honest majority (2t + 1, which is equivalent to n-t). It's also the maximum number of honest parties I can
+// expect to have.
+// When all the validators have the same weight of 1, this is the number of
+// votes.
+// It returns 0 when the validator set is empty.
+func (w *Wendy) HonestMajority() int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return int(w.weight) - int(w.quorum)
+}
+
+// AddTx adds a tx to the list of tx to be mined.
+// AddTx returns false if the tx was already added, if the maximum number of
+// pending txs has been reached (see WithMaxPendingTxs) or if the tx is
+// rejected by the admission policy (see WithAdmissionPolicy), use AddTxE to
+// tell the cases apart.
+func (w *Wendy) AddTx(tx Tx) bool {
+	return w.AddTxE(tx) == nil
+}
+
+// AddTxE is like AddTx, but it returns ErrTxAlreadyAdded if the tx was already
+// added and ErrMaxPendingTxs if the maximum number of pending txs has been
+// reached, so that callers can apply backpressure, or the error returned by
+// the admission policy.
+func (w *Wendy) AddTxE(tx Tx) error {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	if err := w.addTx(tx); err != nil {
+		return err
+	}
+	w.trackEligibleTx(tx)
+	return nil
+}
+
+// addTx pushes the tx unless it was already added, the maximum number of
+// pending txs has been reached or the admission policy rejects it.
+// The caller must hold the txsMtx write lock.
+func (w *Wendy) addTx(tx Tx) error {
+	if w.txs.ByHash(tx.Hash()) != nil {
+		return ErrTxAlreadyAdded
+	}
+	// committed txs are removed by CommitBlock, hence they are never counted.
+	if max := w.maxPendingTxs; max > 0 && len(w.txs.List()) >= max {
+		return ErrMaxPendingTxs
+	}
+	if admit := w.admissionPolicy; admit != nil {
+		if err := admit(tx); err != nil {
+			return err
+		}
+	}
+
+	if len(w.txs.List()) == 0 {
+		w.pendingAt = time.Now()
+	}
+	w.txs.Push(tx)
+	w.invalidateBlockingSet(tx.Hash())
+	w.metrics.txAdded()
+	return nil
+}
+
+// RangeTxs calls fn sequentially for each tx in the order they were added.
+// If fn returns false, RangeTxs stops the iteration.
+// The txs read lock is held during the whole iteration, therefore fn must not
+// call Wendy methods that mutate its state, otherwise it will deadlock.
+func (w *Wendy) RangeTxs(fn func(tx Tx) bool) {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	for _, tx := range w.txs.List() {
+		if !fn(tx) {
+			return
+		}
+	}
+}
+
+// TxsByLabel returns the pending txs with the given label (see Tx) in the
+// order they were added.
+func (w *Wendy) TxsByLabel(label string) []Tx {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	txs := []Tx{}
+	for _, tx := range w.txs.List() {
+		if tx.Label() == label {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// AddTxWithDeadline adds a tx in the same way AddTx does, but the tx will be
+// evicted by EvictExpired once the deadline has passed.
+// AddTxWithDeadline returns false if the tx was already added, in which case
+// its deadline is not updated.
+func (w *Wendy) AddTxWithDeadline(tx Tx, deadline time.Time) bool {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	if err := w.addTx(tx); err != nil {
+		return false
+	}
+	w.trackEligibleTx(tx)
+	w.deadlines[tx.Hash()] = deadline
+	return true
+}
+
+// AddTxWithRound adds a tx along with the consensus round in which it was
+// first proposed, so that blocks built with
+// NewBlockOptions.PreferOlderRounds select the txs that have been waiting for
+// longer first, reducing their starvation as the proposer rotates.
+// The round doesn't change the blocking state of the tx.
+func (w *Wendy) AddTxWithRound(tx Tx, round int64) bool {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	if err := w.addTx(tx); err != nil {
+		return false
+	}
+	w.trackEligibleTx(tx)
+	w.rounds[tx.Hash()] = round
+	return true
+}
+
+// AddTxWithDeps adds a tx that must follow the txs identified by after, e.g
+// txs from the same account with a lower nonce.
+// The declared dependencies are added to the BlockingSet on top of the ones
+// derived from the votes: the tx is always blocked by its pending
+// predecessors, but never blocks them, regardless of the votes. Blocks
+// produced by NewBlockWithOptions never place the tx before any of its
+// predecessors.
+// If the declared dependencies contradict the votes through other txs, the
+// txs form a fairness loop, whose txs are still placed after their declared
+// predecessors by NewBlockWithOptions and Wendy.Order, while BlockingSet.Order
+// can't tell them apart and sorts them by hash.
+// Predecessors that are not pending, either because they haven't been added
+// yet or because they have already been committed, are ignored.
+// IsBlockedBy only reflects the votes.
+func (w *Wendy) AddTxWithDeps(tx Tx, after []Hash) bool {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	if err := w.addTx(tx); err != nil {
+		return false
+	}
+	w.trackEligibleTx(tx)
+	if len(after) > 0 {
+		w.deps[tx.Hash()] = append([]Hash(nil), after...)
+	}
+	return true
+}
+
+// dependsOn returns true if tx1 declared tx2 as a predecessor, see
+// AddTxWithDeps.
+// The caller must hold the txsMtx read lock.
+func (w *Wendy) dependsOn(tx1, tx2 Tx) bool {
+	deps, ok := w.deps[tx1.Hash()]
+	if !ok {
+		return false
+	}
+	hash := tx2.Hash()
+	for _, h := range deps {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedBy is the version of isBlockedBy used to build the BlockingSet, it
+// takes into account the declared dependencies (see AddTxWithDeps).
+// Txs with different labels are only blocked by declared dependencies.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (w *Wendy) blockedBy(tx1, tx2 Tx) bool {
+	if w.dependsOn(tx1, tx2) {
+		return true
+	}
+	if w.dependsOn(tx2, tx1) || tx1.Label() != tx2.Label() {
+		return false
+	}
+	return w.isBlockedBy(tx1, tx2)
+}
+
+// EvictExpired removes the txs whose deadline is before now along with their
+// votes, see RemoveTx.
+// Txs added via AddTx have no deadline and are never evicted.
+// EvictExpired returns the number of txs evicted.
+func (w *Wendy) EvictExpired(now time.Time) int {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	var n int
+	for hash, deadline := range w.deadlines {
+		if deadline.Before(now) && w.removeTx(hash) {
+			n++
+		}
+	}
+	return n
+}
+
+// RemoveTx removes a tx from Wendy's state given its hash.
+// Votes for the tx are removed as well, so that the tx does not contribute to
+// the blocking state of other txs.
+// RemoveTx returns false if the tx is unknown.
+func (w *Wendy) RemoveTx(hash Hash) bool {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	return w.removeTx(hash)
+}
+
+// removeTx is the non locking version of RemoveTx.
+// The caller must hold both the txsMtx and peersMtx write locks.
+func (w *Wendy) removeTx(hash Hash) bool {
+	delete(w.deadlines, hash)
+	delete(w.deps, hash)
+	delete(w.rounds, hash)
+	if ok := w.txs.RemoveByHash(hash); !ok {
+		return false
+	}
+
+	delete(w.votes, hash)
+	delete(w.arrivals, hash)
+	delete(w.eligibleAt, hash)
+	delete(w.signatures, hash)
+	for _, peer := range w.peers {
+		peer.RemoveVote(hash)
+	}
+	for _, peer := range w.removedPeers() {
+		peer.RemoveVote(hash)
+	}
+	w.endTransition(hash)
+	w.invalidateBlockingSet(hash)
+	return true
+}
+
+// AddVote adds a vote to the list of votes.
+// Votes are positioned given it's sequence number.
+// AddVote returns alse if the vote was already added.
+// Votes from peers that are not validators are kept, but they have no weight,
+// hence they don't count towards the quorum.
+func (w *Wendy) AddVote(v *Vote) (bool, error) {
+	var (
+		ok  bool
+		err error
+	)
+	w.addVotesAndNotify(func() {
+		ok, err = w.addVote(v)
+	})
+	return ok, err
+}
+
+// AddSignedVote verifies the vote's signature before adding it (see AddVote).
+// The signed vote is kept so that it can be part of a Certificate.
+// It returns the error from SignedVote.VerifyE, which wraps
+// ErrInvalidSignature, if the verification fails.
+// Signed votes that are in the vote cache (see WithVoteCache) are discarded
+// without being verified, as duplicated votes, it returns false.
+func (w *Wendy) AddSignedVote(sv *SignedVote) (bool, error) {
+	var key Hash
+	if w.voteCache != nil {
+		key = signedVoteKey(sv)
+		if w.voteCache.seen(key) {
+			return false, nil
 		}
 	}
-	w.peers = peers
+
+	if err := sv.VerifyE(); err != nil {
+		return false, err
+	}
+
+	var (
+		ok  bool
+		err error
+	)
+	w.addVotesAndNotify(func() {
+		before := w.numEquivocations(sv.Data.Key())
+		ok, err = w.addVote(sv.Data)
+		if err == nil {
+			w.recordEvidence(sv, before)
+			w.addSignature(sv)
+			if w.voteCache != nil {
+				w.voteCache.add(key)
+			}
+		}
+	})
+	return ok, err
 }
 
-// HonestParties returns the required number of votes to be sure that at least
-// one vote came from a honest validator.
-// t + 1
-func (w *Wendy) HonestParties() int {
-	return w.quorum
+// addVote adds a vote to the list of votes.
+// The caller must hold the peersMtx write lock.
+func (w *Wendy) addVote(v *Vote) (bool, error) {
+	key := ID(v.Pubkey.String())
+	// Register the vote on the peer
+	peer, ok := w.peers[key]
+	if !ok {
+		pub := NewPubkeyFromID(key)
+		peer = w.newPeer(pub)
+		// peers that are not validators don't count towards the quorum.
+		peer.setWeight(w.weights[key])
+		w.peers[key] = peer
+		w.setPeers(w.peers)
+		w.metrics.setPeers(len(w.peers))
+	}
+
+	res, err := peer.addVote(v)
+	if _, ok := w.stale[key]; ok {
+		delete(w.stale, key)
+		w.invalidateBlockingSet()
+	}
+	// even on error the vote might have been added.
+	w.invalidateBlockingSet(v.TxHash)
+	if err != nil {
+		return false, err
+	}
+
+	ok = res == voteAdded
+	w.logger.Debug("Adding vote", "sender", key, "seq", v.Seq, "hash", hex.EncodeToString(v.TxHash[:]), "added", ok)
+	if res == voteDuplicate {
+		w.duplicateVotes++
+	}
+	if ok {
+		v.ReceivedAt = time.Now()
+		if _, ok := w.arrivals[v.TxHash]; !ok {
+			w.arrivals[v.TxHash] = v.ReceivedAt
+		}
+
+		w.votedAt = v.ReceivedAt
+
+		// Register the vote based on its tx.Hash
+		w.votes[v.TxHash] = v
+		w.metrics.voteAdded()
+		close(w.newVotes)
+		w.newVotes = make(chan struct{})
+	}
+	return ok, nil
+}
+
+// DuplicateVoteCount returns the number of votes that were not added because
+// the peer already had a vote for the same tx and sequence number, e.g
+// because the vote was gossiped more than once.
+func (w *Wendy) DuplicateVoteCount() uint64 {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.duplicateVotes
+}
+
+// AddVotes is a helper of AddVote to add more than one vote on a single call,
+// it ignores the return value.
+func (w *Wendy) AddVotes(vs ...*Vote) error {
+	_, err := w.AddVoteBatch(vs)
+	return err
+}
+
+// AddVoteBatch adds a list of votes taking the lock only once, which is
+// cheaper than calling AddVote repeatedly.
+// It returns the number of votes that were added (i.e not duplicated).
+// AddVoteBatch stops on the first error, votes processed before the error
+// remain added.
+func (w *Wendy) AddVoteBatch(vs []*Vote) (int, error) {
+	var (
+		added int
+		err   error
+	)
+	w.addVotesAndNotify(func() {
+		for _, v := range vs {
+			var ok bool
+			if ok, err = w.addVote(v); err != nil {
+				return
+			}
+			if ok {
+				added++
+			}
+		}
+	})
+	return added, err
+}
+
+// OnUnblock registers a callback that is invoked for every tx that becomes
+// unblocked (see IsBlocked) as a result of adding votes.
+// The callback is invoked once per transition, after the votes have been
+// added and without holding any lock, so it is safe to call Wendy methods
+// from it.
+func (w *Wendy) OnUnblock(fn func(tx Tx)) {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.onUnblock = append(w.onUnblock, fn)
+}
+
+// addVotesAndNotify calls add, which is expected to add votes via addVote,
+// holding the locks, and then invokes the OnUnblock callbacks for the txs
+// that have been unblocked by the added votes. The changes of the
+// BlockingSet are published to the SubscribeBlockingChanges subscribers.
+func (w *Wendy) addVotesAndNotify(add func()) {
+	callbacks, unblocked := func() ([]func(Tx), []Tx) {
+		w.txsMtx.RLock()
+		defer w.txsMtx.RUnlock()
+
+		w.peersMtx.Lock()
+		defer w.peersMtx.Unlock()
+
+		return w.changedBy(add)
+	}()
+
+	notifyUnblocked(callbacks, unblocked)
+}
+
+// changedBy is like unblockedBy, but it also publishes the changes of the
+// BlockingSet made by add to the SubscribeBlockingChanges subscribers.
+// The caller must hold the txsMtx read lock and the peersMtx write lock.
+func (w *Wendy) changedBy(add func()) ([]func(Tx), []Tx) {
+	if len(w.blockingSubs) == 0 {
+		return w.unblockedBy(add)
+	}
+
+	prev := w.blockingSet()
+	callbacks, unblocked := w.unblockedBy(add)
+	if delta := diffBlockingSets(prev, w.blockingSet()); !delta.Empty() {
+		w.publishBlockingDelta(delta)
+	}
+	return callbacks, unblocked
+}
+
+// unblockedBy calls add and returns the txs that have been unblocked by it
+// along with a copy of the OnUnblock callbacks, if there are any.
+// The caller must hold the txsMtx read lock and the peersMtx write lock.
+func (w *Wendy) unblockedBy(add func()) ([]func(Tx), []Tx) {
+	if len(w.onUnblock) == 0 {
+		add()
+		w.trackEligible()
+		return nil, nil
+	}
+
+	blocked := w.filterTxsByBlocked(true)
+	add()
+	w.trackEligible()
+
+	var unblocked []Tx
+	for _, tx := range blocked {
+		if !w.isBlocked(tx) {
+			unblocked = append(unblocked, tx)
+		}
+	}
+
+	callbacks := make([]func(Tx), len(w.onUnblock))
+	copy(callbacks, w.onUnblock)
+	return callbacks, unblocked
+}
+
+// trackEligible records the first time every pending tx is seen unblocked,
+// see WithFairnessTimeout.
+// The caller must hold the txsMtx read lock and the peersMtx write lock.
+func (w *Wendy) trackEligible() {
+	if w.fairnessTimeout == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, tx := range w.txs.List() {
+		if _, ok := w.eligibleAt[tx.Hash()]; !ok && !w.isBlocked(tx) {
+			w.eligibleAt[tx.Hash()] = now
+		}
+	}
+}
+
+// trackEligibleTx records tx as unblocked if it already is when added, e.g
+// because its votes arrived first, see WithFairnessTimeout.
+// The caller must hold the txsMtx write lock.
+func (w *Wendy) trackEligibleTx(tx Tx) {
+	if w.fairnessTimeout == 0 {
+		return
+	}
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	if !w.isBlocked(tx) {
+		w.eligibleAt[tx.Hash()] = time.Now()
+	}
+}
+
+// isForced returns true if the tx has been unblocked for longer than the
+// fairness timeout, see WithFairnessTimeout.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) isForced(hash Hash) bool {
+	at, ok := w.eligibleAt[hash]
+	return ok && w.fairnessTimeout > 0 && time.Since(at) >= w.fairnessTimeout
+}
+
+// relaxFairness returns a copy of set where the forced txs (see isForced)
+// are not blocked by any other tx, along with the forced txs, or set itself
+// if there are none.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (w *Wendy) relaxFairness(set BlockingSet) (BlockingSet, map[Hash]bool) {
+	var forced map[Hash]bool
+	relaxed := set
+	for hash := range w.eligibleAt {
+		blockers, ok := set[hash]
+		if !ok || len(blockers) <= 1 || !w.isForced(hash) {
+			continue
+		}
+
+		if forced == nil {
+			forced = make(map[Hash]bool)
+			relaxed = make(BlockingSet, len(set))
+			for h, txs := range set {
+				relaxed[h] = txs
+			}
+		}
+		forced[hash] = true
+		relaxed[hash] = []Tx{w.txs.ByHash(hash)}
+	}
+	return relaxed, forced
+}
+
+// notifyUnblocked invokes every callback for every unblocked tx, it must be
+// called without holding any lock.
+func notifyUnblocked(callbacks []func(Tx), unblocked []Tx) {
+	for _, tx := range unblocked {
+		for _, fn := range callbacks {
+			fn(tx)
+		}
+	}
+}
+
+// ValidateBlock returns an error wrapping ErrUnknownTx, naming the first tx of
+// the block that is not pending (see AddTx), e.g because a proposer stuffed
+// txs that were never gossiped.
+// Known txs that are blocked (see IsBlocked) are not an error, BlockedTxs
+// can be used to find them.
+func (w *Wendy) ValidateBlock(block Block) error {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	for _, tx := range block.Txs {
+		if hash := tx.Hash(); w.txs.ByHash(hash) == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownTx, hex.EncodeToString(hash[:]))
+		}
+	}
+	return nil
+}
+
+// CommitBlock iterate over the block's Txs set and remove them from Wendy's
+// internal state, this includes the txs, their votes and the peers' state.
+// Txs present on block were probbaly added in the past via AddTx().
+func (w *Wendy) CommitBlock(block Block) {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.commitBlock(block)
+}
+
+// commitBlock is the non locking version of CommitBlock.
+// The caller must hold both the txsMtx and peersMtx write locks.
+func (w *Wendy) commitBlock(block Block) {
+	w.audit(block)
+	if w.history != nil {
+		w.history.add(block)
+	}
+
+	var pruned int
+	for _, tx := range block.Txs {
+		hash := tx.Hash()
+		if w.txs.RemoveByHash(hash) {
+			pruned++
+		}
+		delete(w.deadlines, hash)
+		delete(w.deps, hash)
+		delete(w.rounds, hash)
+		delete(w.votes, hash)
+		delete(w.arrivals, hash)
+		delete(w.eligibleAt, hash)
+		delete(w.signatures, hash)
+		w.invalidateBlockingSet(hash)
+	}
+	w.logger.Debug("Committing block", "txs", len(block.Txs), "pruned", pruned)
+
+	for _, peer := range w.peers {
+		peer.UpdateTxSet(block.Txs...)
+	}
+	for _, peer := range w.removedPeers() {
+		peer.UpdateTxSet(block.Txs...)
+	}
+	for _, tx := range block.Txs {
+		w.endTransition(tx.Hash())
+	}
+}
+
+// Compact drops the peers' votes for txs that have already been committed
+// and are no longer pending, which accumulate when votes arrive after
+// CommitBlock. Votes for txs that haven't been added yet are kept.
+// Compact is meant to be called periodically, it returns the number of
+// votes dropped.
+func (w *Wendy) Compact() int {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	pending := func(hash Hash) bool { return w.txs.ByHash(hash) != nil }
+
+	var n int
+	for _, peer := range w.peers {
+		n += peer.compact(pending)
+	}
+
+	for hash := range w.votes {
+		if pending(hash) {
+			continue
+		}
+		var voted bool
+		for _, peer := range w.peers {
+			if peer.voted(hash) {
+				voted = true
+				break
+			}
+		}
+		if !voted {
+			delete(w.votes, hash)
+			delete(w.arrivals, hash)
+			delete(w.signatures, hash)
+		}
+	}
+
+	if n > 0 {
+		w.logger.Debug("Compacting votes", "dropped", n)
+	}
+	return n
+}
+
+// VoteByTxHash returns a vote given its tx.Hash
+// Returns nil if the vote hasn't been seen.
+func (w *Wendy) VoteByTxHash(hash Hash) *Vote {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+	return w.votes[hash]
+}
+
+// PeerVotes returns the votes casted by a given peer ordered by sequence
+// number.
+// The returned votes are a copy, thus they can be modified by the caller.
+// The bool is false if the peer is not known.
+func (w *Wendy) PeerVotes(id ID) ([]*Vote, bool) {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	peer, ok := w.peers[id]
+	if !ok {
+		return nil, false
+	}
+	return peer.Votes(), true
+}
+
+// TxPositions returns the sequence number with which every peer voted for the
+// tx (see Peer.Position) indexed by the peer ID. Peers which did not vote for
+// the tx are not present.
+// The returned map is a copy and can be freely modified.
+func (w *Wendy) TxPositions(tx Tx) map[ID]uint64 {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	m := make(map[ID]uint64)
+	for id, peer := range w.peers {
+		if seq, ok := peer.Position(tx); ok {
+			m[id] = seq
+		}
+	}
+	return m
+}
+
+// Equivocations returns the equivocations produced by the peers indexed by
+// the peer ID. Peers which did not equivocate are not present.
+// A peer equivocates when it votes for two different txs using the same
+// sequence number.
+func (w *Wendy) Equivocations() map[ID][]EquivocationProof {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	m := make(map[ID][]EquivocationProof)
+	for id, peer := range w.peers {
+		if list := peer.Equivocations(); len(list) > 0 {
+			m[id] = list
+		}
+	}
+	return m
+}
+
+// PeerGaps returns the missing sequence numbers (see Peer.Gaps) of every
+// peer for a given label, indexed by the peer ID. Peers without gaps are not
+// present.
+func (w *Wendy) PeerGaps(label string) map[ID][]uint64 {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	m := make(map[ID][]uint64)
+	for id, peer := range w.peers {
+		if gaps := peer.Gaps(label); len(gaps) > 0 {
+			m[id] = gaps
+		}
+	}
+	return m
+}
+
+// ExpireStalePeers stops counting the votes of the peers that have not voted
+// since the cutoff (see Peer.LastSeen), so that offline validators don't
+// influence the blocking state of txs. Peers are kept in the validator set,
+// hence the quorum is not affected, and their votes are counted again as
+// soon as they vote.
+// It returns the IDs of the peers whose votes are not counted, sorted.
+func (w *Wendy) ExpireStalePeers(cutoff time.Time) []ID {
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	w.stale = make(map[ID]struct{})
+	ids := []ID{}
+	for id, peer := range w.peers {
+		if peer.LastSeen().Before(cutoff) {
+			w.stale[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	w.invalidateBlockingSet()
+	return ids
+}
+
+// quorumReached evaluates fn for every registered peer.
+// If txs are given and all of them are part of a validator set transition
+// (see WithGracefulTransition), the previous validator set is used instead.
+// It returns true if the sum of the weights of the peers for which fn returned
+// true reaches w.quorum.
+// Stale peers are not taken into account.
+// The quorum is never reached when the validator set is empty.
+// The caller must hold the peersMtx lock.
+func (w *Wendy) quorumReached(fn func(*Peer) bool, txs ...Tx) bool {
+	// quorum can't be reached while halted, not even by the previous set.
+	if w.isHalted() {
+		return false
+	}
+
+	if t := w.transition; t != nil && t.covers(txs) {
+		return t.quorumReached(w.stale, fn)
+	}
+
+	// quorum can't be reached before the validator set is known.
+	if w.quorum == 0 {
+		return false
+	}
+
+	// peers are iterated in ID order, so that the peers evaluated until the
+	// quorum is reached are the same on every node.
+	var votes uint64
+	for _, id := range w.peerIDs {
+		if _, ok := w.stale[id]; ok {
+			continue
+		}
+		peer := w.peers[id]
+		if ok := fn(peer); ok {
+			votes += peer.Weight()
+			if votes >= w.quorum {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsHalted returns true when the validator set has fewer validators than
+// required (see WithMinValidators). While halted, quorum can't be reached, so
+// that every tx is blocked (see IsBlocked) until the validator set recovers.
+func (w *Wendy) IsHalted() bool {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.isHalted()
+}
+
+// isHalted is the non locking version of IsHalted.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) isHalted() bool {
+	return len(w.validators) < w.minValidators
+}
+
+// IsBlockedBy determines if tx2 might have priority over tx1.
+// We say that tx1 is NOT blocked by tx2 if there are t+1 votes reporting tx1
+// before tx2.
+// Votes are weighted by the voting weight of the peers, see
+// UpdateValidatorSetWeighted.
+// When the validator set is empty, tx1 is always blocked by tx2.
+// Txs with different labels never block each other, see Tx.
+func (w *Wendy) IsBlockedBy(tx1, tx2 Tx) bool {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.isBlockedBy(tx1, tx2)
+}
+
+// isBlockedBy is the non locking version of IsBlockedBy.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) isBlockedBy(tx1, tx2 Tx) bool {
+	// txs with different labels are ordered independently.
+	if tx1.Label() != tx2.Label() {
+		return false
+	}
+
+	// if there's no quorum that tx1 is before tx2, then tx1 is Blocked by tx2
+	return !w.quorumReached(func(p *Peer) bool {
+		return p.Before(tx1, tx2)
+	}, tx1, tx2)
+}
+
+// Priority compares the fair priority of tx1 and tx2.
+// It returns -1 if tx1 has priority over tx2 (i.e there's a quorum reporting
+// tx1 before tx2), +1 if tx2 has priority over tx1 and 0 if it can't be
+// determined (either because of a fairness loop, not enough votes or txs
+// having different labels).
+// Priority can be used as a comparator to sort txs.
+func (w *Wendy) Priority(tx1, tx2 Tx) int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.priority(tx1, tx2)
+}
+
+// priority is the non locking version of Priority.
+// The caller must hold the peersMtx lock.
+func (w *Wendy) priority(tx1, tx2 Tx) int {
+	// txs with different labels are ordered independently.
+	if tx1.Label() != tx2.Label() {
+		return 0
+	}
+
+	before := w.quorumReached(func(p *Peer) bool {
+		return p.Before(tx1, tx2)
+	}, tx1, tx2)
+	after := w.quorumReached(func(p *Peer) bool {
+		return p.Before(tx2, tx1)
+	}, tx1, tx2)
+
+	switch {
+	case before && !after:
+		return -1
+	case after && !before:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CanCommit returns true if committing txs does not violate fairness against
+// the known pending txs, that is, there's no unblocked pending tx outside txs
+// with priority (see Priority) over any of the txs.
+func (w *Wendy) CanCommit(txs []Tx) bool {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	set := NewTxs(txs...)
+	for _, pending := range w.txs.List() {
+		if set.ByHash(pending.Hash()) != nil || w.isBlocked(pending) {
+			continue
+		}
+
+		for _, tx := range txs {
+			if w.priority(pending, tx) < 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsFinal returns true if the position of tx relative to the rest of the txs
+// can't change anymore, which is a stronger guarantee than !IsBlocked.
+// A peer that has seen tx (see Peer.Seen) can't reorder it anymore, any tx it
+// votes afterwards will come after tx. Thus, tx is final when it is not
+// blocked and, for every other tx with the same label, a quorum of the peers
+// that have seen the earlier of both txs agree on their order.
+// Given n = 3t+1 validators where up to t can be faulty, the quorum is 2t+1
+// and the remaining weight (HonestMajority, n - (2t+1) = t) is not enough to
+// reach a quorum for the opposite order.
+func (w *Wendy) IsFinal(tx Tx) bool {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	if w.isBlocked(tx) {
+		return false
+	}
+
+	hash := tx.Hash()
+	for _, tx2 := range w.txs.List() {
+		if tx2.Hash() == hash || tx2.Label() != tx.Label() {
+			continue
+		}
+
+		before := w.quorumReached(func(p *Peer) bool {
+			return p.Seen(tx) && p.Before(tx, tx2)
+		}, tx, tx2)
+		after := w.quorumReached(func(p *Peer) bool {
+			return p.Seen(tx2) && p.Before(tx2, tx)
+		}, tx, tx2)
+		if !before && !after {
+			return false
+		}
+	}
+	return true
+}
+
+// IsFinalWeighted returns true if the voting weight of the validators that
+// have seen tx (see Peer.Seen) reaches the weighted quorum, so that no tx
+// unseen so far can be ordered before it: the remaining weight, up to the
+// honest majority threshold (see HonestMajority), is below the quorum. The
+// weight of t faulty validators alone is not enough, hence a tx is never
+// final while it's blocked (see IsBlocked).
+// Unlike IsFinal, it only takes into account the stake backing tx, not its
+// order relative to the rest of the pending txs.
+// It always returns false when the validator set is empty or Wendy is halted
+// (see IsHalted).
+func (w *Wendy) IsFinalWeighted(tx Tx) bool {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	seen, quorum, _ := w.seenWeight(tx)
+	return quorum > 0 && seen >= quorum
+}
+
+// SeenCount returns the number of validators that have seen the tx (see
+// Peer.Seen), regardless of their weight. Peers that are not validators and
+// stale peers (see ExpireStalePeers) are not counted.
+func (w *Wendy) SeenCount(tx Tx) int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.countPeers(func(p *Peer) bool { return p.Seen(tx) })
+}
+
+// VoteCount returns the number of validators that have voted for the tx (see
+// Peer.Voted), counted as SeenCount does.
+func (w *Wendy) VoteCount(tx Tx) int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.countPeers(func(p *Peer) bool { return p.Voted(tx) })
+}
+
+// BeforeCount returns the number of validators that have reported tx1 before
+// tx2 (see Peer.Before), counted as SeenCount does, which is the raw signal
+// behind IsBlockedBy.
+// Txs with different labels are never ordered, hence it returns 0.
+func (w *Wendy) BeforeCount(tx1, tx2 Tx) int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.beforeCount(tx1, tx2)
+}
+
+// beforeCount is the non locking version of BeforeCount.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) beforeCount(tx1, tx2 Tx) int {
+	if tx1.Label() != tx2.Label() {
+		return 0
+	}
+	return w.countPeers(func(p *Peer) bool { return p.Before(tx1, tx2) })
+}
+
+// PreferredOrder orders the txs of a fairness loop (see BlockingSet.Cycles)
+// by their Copeland score: every tx scores a win against each tx of the loop
+// reported after it by more peers than before it (see BeforeCount), and half
+// a win on ties. Txs with more wins are placed first, ties are broken by hash
+// order. Hashes of txs that are not pending are placed last, in hash order.
+func (w *Wendy) PreferredOrder(cycle []Hash) []Hash {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	// scores are doubled so that ties score 1.
+	scores := make(map[Hash]int, len(cycle))
+	for i, h1 := range cycle {
+		tx1 := w.txs.ByHash(h1)
+		if tx1 == nil {
+			scores[h1] = -1
+			continue
+		}
+		for _, h2 := range cycle[i+1:] {
+			tx2 := w.txs.ByHash(h2)
+			if tx2 == nil || tx1.Label() != tx2.Label() {
+				continue
+			}
+			switch n1, n2 := w.beforeCount(tx1, tx2), w.beforeCount(tx2, tx1); {
+			case n1 > n2:
+				scores[h1] += 2
+			case n2 > n1:
+				scores[h2] += 2
+			default:
+				scores[h1]++
+				scores[h2]++
+			}
+		}
+	}
+
+	order := append([]Hash(nil), cycle...)
+	sortHashes(order)
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	return order
+}
+
+// countPeers returns the number of validators for which fn is true, ignoring
+// their weight. As for quorumReached, peers that are not validators and stale
+// peers (see ExpireStalePeers) are not taken into account.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) countPeers(fn func(*Peer) bool) int {
+	var n int
+	for _, id := range w.peerIDs {
+		if _, ok := w.weights[id]; !ok {
+			continue
+		}
+		if _, ok := w.stale[id]; ok {
+			continue
+		}
+		if fn(w.peers[id]) {
+			n++
+		}
+	}
+	return n
+}
+
+// IsBlocked identifies if it is pssible that a so-far-unknown transaction
+// might be scheduled with priority to tx.
+// When the validator set is empty or Wendy is halted (see IsHalted), every tx
+// is blocked.
+func (w *Wendy) IsBlocked(tx Tx) bool {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.isBlocked(tx)
 }
 
-// HonestMajority returns the minimum number of votes required to assure that I
-// have a honest majority (2t + 1, which is equivalent to n-t). It's also the maximum number of honest parties I can
-// expect to have.
-func (w *Wendy) HonestMajority() int {
-	return len(w.validators) - w.quorum
+// isBlocked is the non locking version of IsBlocked.
+// The caller must hold the peersMtx lock.
+func (w *Wendy) isBlocked(tx Tx) bool {
+	// if there's no quorum that tx has been seen, then IsBlocked
+	return !w.quorumReached(func(p *Peer) bool {
+		return p.Seen(tx)
+	}, tx)
 }
 
-// AddTx adds a tx to the list of tx to be mined.
-// AddTx returns false if the tx was already added.
-func (w *Wendy) AddTx(tx Tx) bool {
-	w.txsMtx.Lock()
-	defer w.txsMtx.Unlock()
+// VotesUntilUnblocked returns the voting weight of the additional peers that
+// need to see tx (see Peer.Seen) for it to become unblocked (see IsBlocked).
+// It returns 0 if tx is already unblocked, and -1 if the validator set is
+// empty, since txs can't be unblocked without validators.
+// When all the validators have the same weight of 1, this is the number of
+// votes.
+func (w *Wendy) VotesUntilUnblocked(tx Tx) int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
 
-	return w.txs.Push(tx)
+	seen, quorum, _ := w.seenWeight(tx)
+	if quorum == 0 {
+		return -1
+	}
+	if seen >= quorum {
+		return 0
+	}
+	return int(quorum - seen)
 }
 
-// AddVote adds a vote to the list of votes.
-// Votes are positioned given it's sequence number.
-// AddVote returns alse if the vote was already added.
-func (w *Wendy) AddVote(v *Vote) (bool, error) {
-	w.peersMtx.Lock()
-	defer w.peersMtx.Unlock()
+// VotesUntilBlocked returns the voting weight of the peers that have seen tx
+// that would need to stop being counted (e.g because they become stale, see
+// ExpireStalePeers) for it to become blocked again.
+// It returns 0 if tx is already blocked.
+func (w *Wendy) VotesUntilBlocked(tx Tx) int {
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
 
-	key := ID(v.Pubkey.String())
-	// Register the vote on the peer
-	peer, ok := w.peers[key]
-	if !ok {
-		pub := NewPubkeyFromID(key)
-		peer = NewPeer(pub)
-		w.peers[key] = peer
+	seen, quorum, _ := w.seenWeight(tx)
+	if quorum == 0 || seen < quorum {
+		return 0
 	}
+	return int(seen - quorum + 1)
+}
 
-	ok, err := peer.AddVote(v)
-	if err != nil {
-		return false, err
+// seenWeight returns the voting weight of the peers that have seen tx along
+// with the quorum and the total voting weight, from the same validator set
+// isBlocked uses.
+// The quorum is 0 when it can't be reached, see quorumReached.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) seenWeight(tx Tx) (seen, quorum, total uint64) {
+	// quorum can't be reached before the validator set is known nor while
+	// halted.
+	if w.quorum == 0 || w.isHalted() {
+		return 0, 0, 0
 	}
 
-	// Register the vote based on its tx.Hash
-	w.votes[v.TxHash] = v
-	return ok, nil
-}
+	peers, weight := w.peers, func(_ ID, p *Peer) uint64 { return p.Weight() }
+	quorum, total = w.quorum, w.weight
+	if t := w.transition; t != nil && t.covers([]Tx{tx}) {
+		peers, weight = t.peers, func(id ID, _ *Peer) uint64 { return t.weights[id] }
+		quorum, total = t.quorum, 0
+		for _, v := range t.weights {
+			total += v
+		}
+	}
 
-func (w *Wendy) AddVotes(vs ...*Vote) error {
-	for _, v := range vs {
-		if _, err := w.AddVote(v); err != nil {
-			return err
+	for id, peer := range peers {
+		if _, ok := w.stale[id]; ok {
+			continue
+		}
+		if peer.Seen(tx) {
+			seen += weight(id, peer)
 		}
 	}
-	return nil
+	return seen, quorum, total
 }
 
-// CommitBlock iterate over the block's Txs set and remove them from Wendy's
-// internal state.
-// Txs present on block were probbaly added in the past via AddTx().
-func (w *Wendy) CommitBlock(block Block) {
-	w.peersMtx.Lock()
-	defer w.peersMtx.Unlock()
+// WaitUntilUnblocked blocks until tx is not blocked (see IsBlocked) or the
+// context is done, in which case ctx.Err() is returned.
+// The blocking state is re-evaluated every time a new vote is added.
+func (w *Wendy) WaitUntilUnblocked(ctx context.Context, tx Tx) error {
+	for {
+		w.peersMtx.RLock()
+		blocked, newVotes := w.isBlocked(tx), w.newVotes
+		w.peersMtx.RUnlock()
 
-	for _, peer := range w.peers {
-		peer.UpdateTxSet(block.Txs...)
+		if !blocked {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-newVotes:
+		}
 	}
 }
 
-// VoteByTxHash returns a vote given its tx.Hash
-// Returns nil if the vote hasn't been seen.
-func (w *Wendy) VoteByTxHash(hash Hash) *Vote {
+// BlockedTxs returns all the txs for which IsBlocked is true.
+func (w *Wendy) BlockedTxs() []Tx {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
 	w.peersMtx.RLock()
 	defer w.peersMtx.RUnlock()
-	return w.votes[hash]
+
+	return w.filterTxsByBlocked(true)
 }
 
-// hasQuorum evaluates fn for every registered peer.
-// It returns true if fn returned true at least w.Quorum() times.
-// NOTE: This function is safe for concurrent access.
-func (w *Wendy) hasQuorum(fn func(*Peer) bool) bool {
+// IsStalled returns true when Wendy might not be making progress: there are
+// pending txs but all of them are blocked (see IsBlocked) and no vote has been
+// added for the stall timeout (see WithStallTimeout), e.g because the votes
+// are too sparse to reach a quorum.
+// Txs added while there were none pending restart the timeout, so that a
+// long period without txs is not taken as a stall.
+// Note that fairness loops don't stall Wendy, since their txs are unblocked
+// and included in the same block.
+func (w *Wendy) IsStalled() bool {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
 	w.peersMtx.RLock()
 	defer w.peersMtx.RUnlock()
 
-	var votes int
-	for _, peer := range w.peers {
-		if ok := fn(peer); ok {
-			votes++
-			if votes == w.quorum {
-				return true
-			}
+	txs := w.txs.List()
+	if len(txs) == 0 {
+		return false
+	}
+
+	progress := w.pendingAt
+	if w.votedAt.After(progress) {
+		progress = w.votedAt
+	}
+	if time.Since(progress) < w.stallTimeout {
+		return false
+	}
+
+	for _, tx := range txs {
+		if !w.isBlocked(tx) {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-// IsBlockedBy determines if tx2 might have priority over tx1.
-// We say that tx1 is NOT blocked by tx2 if there are t+1 votes reporting tx1
-// before tx2.
-func (w *Wendy) IsBlockedBy(tx1, tx2 Tx) bool {
-	// if there's no quorum that tx1 is before tx2, then tx1 is Blocked by tx2
-	return !w.hasQuorum(func(p *Peer) bool {
-		return p.Before(tx1, tx2)
-	})
+// UnblockedTxs returns all the txs for which IsBlocked is false.
+func (w *Wendy) UnblockedTxs() []Tx {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.filterTxsByBlocked(false)
 }
 
-// IsBlocked identifies if it is pssible that a so-far-unknown transaction
-// might be scheduled with priority to tx.
-func (w *Wendy) IsBlocked(tx Tx) bool {
-	// if there's no quorum that tx has been seen, then IsBlocked
-	return !w.hasQuorum(func(p *Peer) bool {
-		return p.Seen(tx)
-	})
+// filterTxsByBlocked returns the txs whose blocked state equals blocked.
+// The caller must hold the txsMtx and peersMtx read locks, so that all the
+// txs are evaluated under the same lock and results are consistent.
+func (w *Wendy) filterTxsByBlocked(blocked bool) []Tx {
+	txs := []Tx{}
+	for _, tx := range w.txs.List() {
+		if w.isBlocked(tx) == blocked {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
 }
 
 // AddBlock will clean all the added txs (via AddTx).
 // Once a block has been added, all the txs will be removed from Wendy, thus
 // new blocks (NewBlock) won't return them anymore.
+// AddBlock is equivalent to CommitBlock.
 func (w *Wendy) AddBlock(block *Block) {
-	w.txsMtx.Lock()
-	defer w.txsMtx.Unlock()
-	for _, tx := range block.Txs {
-		w.txs.RemoveByHash(tx.Hash())
-	}
-
-	w.peersMtx.Lock()
-	defer w.peersMtx.Unlock()
-	for _, peer := range w.peers {
-		peer.UpdateTxSet(block.Txs...)
-	}
+	w.CommitBlock(*block)
 }
 
 // NewBlockOptions are options that control the behaviour of NewBlock method.
+// When both TxLimit and MaxBlockSize are set, txs are included until any of the
+// limits is reached, whichever comes first.
+// Since a tx is always included along with its blocking txs, when limits are
+// applied, txs blocking the largest number of txs (i.e the ones with more
+// dependants) are included first, ties are broken by hash order.
 type NewBlockOptions struct {
 	// TxLimit limits the maximum number of Txs that a produced block might
 	// contain.
@@ -202,19 +1720,113 @@ type NewBlockOptions struct {
 
 	// MaxBlockSize limits the maximum size of a block.
 	// MaxBlockSize is set in bytes and is computed as the sum of all
-	// `len(tx.Bytes())`.
+	// `len(tx.Bytes())` plus PerTxOverhead.
 	// If a tx makes exceed the BlockSize, it is removed from the block and the
 	// function returns.
 	// NewBlock will not try to optimize for space.
 	MaxBlockSize int
 
+	// PerTxOverhead is added to the size of every tx when checking the
+	// MaxBlockSize, so that space can be reserved for the data attached to
+	// each tx, e.g its fairness proof (votes).
+	PerTxOverhead int
+
 	// AddBlock flag determines if the newly created block should be also added.
 	AddBlock bool
+
+	// LabelFilter, when set, restricts the block to the txs whose label
+	// passes the filter. The filter is applied before any of the limits.
+	// Txs blocked by txs that don't pass the filter, e.g declared
+	// dependencies with a different label (see AddTxWithDeps), are not
+	// selected either.
+	LabelFilter func(label string) bool
+
+	// MinTxs is the minimum number of unblocked txs (see IsBlocked) required
+	// to produce a block. If there are fewer, an empty block is returned.
+	// Txs that don't pass the LabelFilter are not taken into account.
+	MinTxs int
+
+	// Exclude holds the hashes of the txs that must not be part of the block,
+	// e.g because they have already been proposed. Excluded txs are treated
+	// as already included: they don't count towards the limits, and the txs
+	// they block can still be part of the block.
+	Exclude map[Hash]bool
+
+	// TieBreak determines how the txs of a fairness loop are ordered within
+	// the block, by default they are sorted by hash.
+	TieBreak TieBreak
+
+	// PreferOlderRounds selects the unblocked txs by ascending round (see
+	// AddTxWithRound) before the limits are applied, so that txs proposed in
+	// older rounds are included first. Txs added without a round are
+	// considered the newest. The txs of the block are still sorted by hash.
+	// BlockingSet.BlockStats ignores it, since rounds are part of Wendy's
+	// state, Wendy.BlockStats doesn't.
+	PreferOlderRounds bool
+
+	// Priority, when set, determines the order in which the unblocked txs
+	// are selected, it must return true if a has to be selected before b,
+	// e.g because it pays a higher fee. The selection stops as soon as the
+	// next tx (along with its blocking txs) doesn't fit in the limits, so
+	// the highest priority txs are kept. Ties keep the default order.
+	// The txs of the block are still sorted by hash.
+	Priority func(a, b Tx) bool
+
+	// MustInclude holds the hashes of txs that are always part of the block,
+	// e.g governance txs, even if they are blocked (see IsBlocked) or don't
+	// pass the LabelFilter. Their blocking txs are included along with them,
+	// and the rest of the block is filled in the usual way with the space
+	// left. Must-include txs are not subject to TxLimit, MaxBlockSize nor
+	// MinTxs, and hashes of txs that are not pending are ignored.
+	// This is opt-in and can violate strict fairness, since a must-include tx
+	// can be ordered before txs that a quorum has seen earlier.
+	MustInclude []Hash
+
+	// mustOnly is set by newBlock when MinTxs is not reached, so that only
+	// the MustInclude txs are selected.
+	mustOnly bool
+
+	// rounds is set by NewBlockWithOptions when PreferOlderRounds is set.
+	rounds map[Hash]int64
+
+	// forced holds the txs to be selected first, see WithFairnessTimeout.
+	forced map[Hash]bool
+}
+
+// txSize returns the size of tx accounted for MaxBlockSize.
+func (opts NewBlockOptions) txSize(tx Tx) int {
+	return len(tx.Bytes()) + opts.PerTxOverhead
 }
 
+// TieBreak is the ordering criteria for txs that are part of a fairness loop,
+// since none of them is blocked by the others.
+type TieBreak int
+
+const (
+	// ByHash sorts the txs of a fairness loop by hash, this is the default
+	// and the only deterministic criteria across nodes.
+	ByHash TieBreak = iota
+
+	// ByArrivalTime sorts the txs of a fairness loop by the earliest time a
+	// vote for them was received (see Vote.ReceivedAt).
+	// Since receive times are local, blocks built with ByArrivalTime might
+	// differ among nodes. Txs with the same arrival time, or without votes,
+	// fallback to hash order.
+	ByArrivalTime
+
+	// Random shuffles the txs of a fairness loop using the source of
+	// randomness set by WithRand, so that no tx is favoured by its hash.
+	// Blocks built with Random differ among nodes unless they share a seeded
+	// source. If reading from the source fails, the loop is kept in hash
+	// order.
+	Random
+)
+
 // NewBlock produces a potential block given the computed BlockingSet.
 // The new block will contain a set of Txs that need to go all together in the
 // same block.
+// Block's Txs are sorted by hash, so that all the nodes produce the same
+// block given the same state.
 func (w *Wendy) NewBlock() *Block {
 	return w.NewBlockWithOptions(
 		NewBlockOptions{},
@@ -225,67 +1837,378 @@ func (w *Wendy) NewBlock() *Block {
 // BlockingSet and a set of options.
 // The new block will contain a set of Txs that need to go all
 // together in the same block.
+// Txs that are part of a fairness loop are always included together, they
+// are sorted by hash as the rest of the txs (unless opts.TieBreak says
+// otherwise) and flagged in Block.Cyclic.
 func (w *Wendy) NewBlockWithOptions(opts NewBlockOptions) *Block {
+	if opts.AddBlock {
+		w.txsMtx.Lock()
+		defer w.txsMtx.Unlock()
+
+		w.peersMtx.Lock()
+		defer w.peersMtx.Unlock()
+
+		block := w.newBlock(w.blockingSet(), opts)
+		w.commitBlock(*block)
+		return block
+	}
+
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.newBlock(w.blockingSet(), opts)
+}
+
+// NewBlockFromSet builds a block in the same way NewBlockWithOptions does, but
+// out of the given set instead of the current BlockingSet, e.g a subset of it
+// (see BlockingSet.Filter), so that staged blocks don't need to recompute it.
+// opts.AddBlock is ignored, see CommitBlock.
+func (w *Wendy) NewBlockFromSet(set BlockingSet, opts NewBlockOptions) *Block {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.newBlock(set, opts)
+}
+
+// BlockStats returns the number of txs and the sum of their sizes of the
+// block that NewBlockWithOptions would produce given the options, without
+// building it, see BlockingSet.BlockStats.
+// Unlike BlockingSet.BlockStats, it takes into account opts.MinTxs,
+// opts.PreferOlderRounds and the fairness timeout (see WithFairnessTimeout).
+func (w *Wendy) BlockStats(opts NewBlockOptions) (numTxs int, totalBytes int) {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	set, opts, ok := w.prepareSelection(w.blockingSet(), opts)
+	if !ok {
+		return 0, 0
+	}
+	return set.BlockStats(opts)
+}
+
+// DrainUnblocked builds a block in the same way NewBlockWithOptions does, but
+// only with the txs that are not blocked and whose blocking txs are not
+// blocked either (besides opts.MustInclude and the txs forced by the fairness
+// timeout, see WithFairnessTimeout), and removes them from Wendy as
+// CommitBlock does.
+// DrainUnblocked holds the write locks during the whole operation, so that
+// no votes can be added between building the block and committing it.
+// opts.AddBlock is ignored, since the block is always committed.
+func (w *Wendy) DrainUnblocked(opts NewBlockOptions) Block {
+	w.txsMtx.Lock()
+	defer w.txsMtx.Unlock()
+
+	w.peersMtx.Lock()
+	defer w.peersMtx.Unlock()
+
+	must := make(map[Hash]bool, len(opts.MustInclude))
+	for _, hash := range opts.MustInclude {
+		must[hash] = true
+	}
+
+	set := w.blockingSet()
+	for hash, blockers := range set {
+		if must[hash] || w.isForced(hash) {
+			continue
+		}
+		for _, tx := range blockers {
+			if w.isBlocked(tx) {
+				delete(set, hash)
+				break
+			}
+		}
+	}
+
+	block := w.newBlock(set, opts)
+	w.commitBlock(*block)
+	return *block
+}
+
+// newBlock builds a block out of set given the options.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (w *Wendy) newBlock(set BlockingSet, opts NewBlockOptions) *Block {
+	set, opts, ok := w.prepareSelection(set, opts)
+	if !ok {
+		return &Block{Txs: []Tx{}}
+	}
+
+	txs := set.selectTxs(opts)
+	switch opts.TieBreak {
+	case ByArrivalTime:
+		w.sortCyclesByArrival(set, txs)
+	case Random:
+		w.shuffleCycles(set, txs)
+	}
+	if len(w.deps) > 0 {
+		txs = w.sortByDeps(txs)
+	}
+
+	var forced map[Hash]bool
+	for _, tx := range txs {
+		if opts.forced[tx.Hash()] {
+			if forced == nil {
+				forced = make(map[Hash]bool)
+			}
+			forced[tx.Hash()] = true
+		}
+	}
+	return &Block{
+		Txs:    txs,
+		Cyclic: set.cyclic(txs),
+		Forced: forced,
+	}
+}
+
+// prepareSelection applies the options that depend on Wendy's state, i.e
+// MinTxs, PreferOlderRounds and the fairness timeout (see
+// WithFairnessTimeout), returning the set and options to select the txs of a
+// block from. It returns false if the block must be empty.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (w *Wendy) prepareSelection(set BlockingSet, opts NewBlockOptions) (BlockingSet, NewBlockOptions, bool) {
+	if opts.MinTxs > 0 && w.countUnblocked(opts.LabelFilter) < opts.MinTxs {
+		if len(opts.MustInclude) == 0 {
+			return set, opts, false
+		}
+		opts.mustOnly = true
+	}
+
+	if opts.PreferOlderRounds {
+		opts.rounds = w.rounds
+	}
+	if w.fairnessTimeout > 0 {
+		set, opts.forced = w.relaxFairness(set)
+	}
+	return set, opts, true
+}
+
+// Order returns the txs of set in dependency order as BlockingSet.Order does,
+// but the txs of every fairness loop are placed after their declared
+// predecessors (see AddTxWithDeps), ties are still broken by hash order.
+// ErrFairnessLoop is returned along with the order if the set holds any
+// fairness loop.
+func (w *Wendy) Order(set BlockingSet) ([]Tx, error) {
 	w.txsMtx.RLock()
 	defer w.txsMtx.RUnlock()
 
+	return set.order(w.sortByDeps)
+}
+
+// sortByDeps returns txs reordered so that no tx is placed before any of its
+// declared predecessors (see AddTxWithDeps), otherwise the order of txs is
+// kept.
+// The caller must hold the txsMtx read lock.
+func (w *Wendy) sortByDeps(txs []Tx) []Tx {
+	sorted := make([]Tx, 0, len(txs))
+	for _, i := range topoSort(w.depIndexes(txs)) {
+		sorted = append(sorted, txs[i])
+	}
+	return sorted
+}
+
+// depIndexes returns, for every tx of txs, the indexes of its declared
+// predecessors (see AddTxWithDeps) that are part of txs.
+// The caller must hold the txsMtx read lock.
+func (w *Wendy) depIndexes(txs []Tx) [][]int {
+	index := make(map[Hash]int, len(txs))
+	for i, tx := range txs {
+		index[tx.Hash()] = i
+	}
+
+	preds := make([][]int, len(txs))
+	for i, tx := range txs {
+		for _, hash := range w.deps[tx.Hash()] {
+			if j, ok := index[hash]; ok && j != i {
+				preds[i] = append(preds[i], j)
+			}
+		}
+	}
+	return preds
+}
+
+// topoSort returns the indexes of the items whose predecessors are given by
+// preds, so that no item is placed before any of its predecessors, using
+// Kahn's algorithm. Among the items whose predecessors are placed, the lowest
+// index goes first. If the predecessors form a loop, the lowest index not
+// placed yet is placed next.
+func topoSort(preds [][]int) []int {
 	var (
-		// these are used to keep track of the different limits
-		size int
+		pending = make([]int, len(preds))   // pending counts the predecessors not placed yet.
+		succs   = make([][]int, len(preds)) // succs is the inverse of preds.
+		placed  = make([]bool, len(preds))
+		ready   = &intHeap{}
+		order   = make([]int, 0, len(preds))
 	)
+	for i, ps := range preds {
+		pending[i] = len(ps)
+		for _, p := range ps {
+			succs[p] = append(succs[p], i)
+		}
+		if len(ps) == 0 {
+			heap.Push(ready, i)
+		}
+	}
+
+	place := func(i int) {
+		placed[i] = true
+		order = append(order, i)
+		for _, s := range succs[i] {
+			if pending[s]--; pending[s] == 0 && !placed[s] {
+				heap.Push(ready, s)
+			}
+		}
+	}
+
+	// next is the lowest index that might not be placed yet, it's the
+	// fallback when the predecessors form a loop.
+	for next := 0; len(order) < len(preds); {
+		if ready.Len() > 0 {
+			if i := heap.Pop(ready).(int); !placed[i] {
+				place(i)
+			}
+			continue
+		}
+		for placed[next] {
+			next++
+		}
+		place(next)
+	}
+	return order
+}
 
-	txs := NewTxs()
-	set := w.BlockingSet()
+// intHeap is a min-heap of ints, see container/heap.
+type intHeap []int
 
-	for _, tx := range w.txs.List() {
-		list := set[tx.Hash()]
-		for _, tx := range list {
-			if limit := opts.TxLimit; limit > 0 {
-				if len(txs.List()) == limit {
-					break
-				}
+func (h intHeap) Len() int            { return len(h) }
+func (h intHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// sortCyclesByArrival reorders in place the txs of every fairness loop by
+// their arrival time. Each loop keeps the positions it had in txs, so that
+// the rest of the txs are not moved.
+// The caller must hold the peersMtx read lock.
+func (w *Wendy) sortCyclesByArrival(set BlockingSet, txs []Tx) {
+	reorderCycles(set, txs, func(group []Tx) {
+		// group is sorted by hash already, a stable sort keeps it as the
+		// fallback.
+		sort.SliceStable(group, func(i, j int) bool {
+			ti, oki := w.arrivals[group[i].Hash()]
+			tj, okj := w.arrivals[group[j].Hash()]
+			if !oki || !okj {
+				return oki && !okj
+			}
+			return ti.Before(tj)
+		})
+	})
+}
+
+// shuffleCycles reorders in place the txs of every fairness loop randomly
+// using w.rand, see Random. Each loop keeps the positions it had in txs.
+func (w *Wendy) shuffleCycles(set BlockingSet, txs []Tx) {
+	reorderCycles(set, txs, func(group []Tx) {
+		// a shuffle is applied only once fully drawn, so that a failing
+		// source keeps the hash order.
+		swaps := make([]int, len(group))
+		for i := len(group) - 1; i > 0; i-- {
+			var n uint64
+			if err := binary.Read(w.rand, binary.BigEndian, &n); err != nil {
+				return
 			}
+			swaps[i] = int(n % uint64(i+1))
+		}
+		for i := len(group) - 1; i > 0; i-- {
+			group[i], group[swaps[i]] = group[swaps[i]], group[i]
+		}
+	})
+}
 
-			if max := opts.MaxBlockSize; max > 0 {
-				size += len(tx.Bytes())
-				if size > max {
+// reorderCycles calls reorder with the txs of every fairness loop that are
+// part of txs, in the order they appear, and places them back in the
+// positions they had, so that the rest of the txs are not moved.
+func reorderCycles(set BlockingSet, txs []Tx, reorder func(group []Tx)) {
+	for _, cycle := range set.Cycles() {
+		var idxs []int
+		for i, tx := range txs {
+			for _, hash := range cycle {
+				if tx.Hash() == hash {
+					idxs = append(idxs, i)
 					break
 				}
 			}
-
-			txs.Push(tx)
 		}
-	}
 
-	block := &Block{
-		Txs: txs.List(),
+		group := make([]Tx, len(idxs))
+		for i, idx := range idxs {
+			group[i] = txs[idx]
+		}
+		reorder(group)
+		for i, idx := range idxs {
+			txs[idx] = group[i]
+		}
 	}
+}
 
-	if opts.AddBlock {
-		w.AddBlock(block)
+// countUnblocked returns the number of unblocked txs whose label passes the
+// filter, if any.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (w *Wendy) countUnblocked(filter func(string) bool) int {
+	var n int
+	for _, tx := range w.txs.List() {
+		if filter != nil && !filter(tx.Label()) {
+			continue
+		}
+		if !w.isBlocked(tx) {
+			n++
+		}
 	}
-
-	return block
+	return n
 }
 
 // BlockingSet returns a list of blocking Txs for all the currently seen Txs.
 func (w *Wendy) BlockingSet() BlockingSet {
+	w.txsMtx.RLock()
+	defer w.txsMtx.RUnlock()
+
+	w.peersMtx.RLock()
+	defer w.peersMtx.RUnlock()
+
+	return w.blockingSet()
+}
+
+// blockingSet is the non locking version of BlockingSet.
+// The blocking txs of every tx are computed in parallel (see parallelFor),
+// the locks held by the caller keep the state read-only meanwhile.
+// The caller must hold the txsMtx and peersMtx read locks.
+func (w *Wendy) blockingSet() BlockingSet {
 	txs := w.txs.List()
 
-	// Build the dependency matrix for all Txs
+	// Build the dependency matrix for all Txs, every row is independent.
 	var matrix [][]bool = make([][]bool, len(txs))
-	for i := range matrix {
-		matrix[i] = make([]bool, len(txs))
-	}
-	for i, tx1 := range w.txs.List() {
+	parallelFor(len(txs), func(i int) {
+		row := make([]bool, len(txs))
 		for j, tx2 := range txs {
-			matrix[i][j] = w.IsBlockedBy(tx1, tx2)
+			row[j] = w.blockedBy(txs[i], tx2)
 		}
-	}
+		matrix[i] = row
+	})
 
-	set := BlockingSet{}
-	for i, tx := range txs {
-		blockers := []Tx{}
+	blockers := make([][]Tx, len(txs))
+	parallelFor(len(txs), func(i int) {
 		deps := make(map[int]struct{})
 		recompute(matrix, i, deps)
 
@@ -295,11 +2218,17 @@ func (w *Wendy) BlockingSet() BlockingSet {
 		}
 		sort.Sort(keys)
 
-		for txIndex := range keys {
-			blockers = append(blockers, txs[txIndex])
+		list := make([]Tx, 0, len(keys))
+		for _, txIndex := range keys {
+			list = append(list, txs[txIndex])
 		}
+		sortTxs(list)
+		blockers[i] = list
+	})
 
-		set[tx.Hash()] = blockers
+	set := make(BlockingSet, len(txs))
+	for i, tx := range txs {
+		set[tx.Hash()] = blockers[i]
 	}
 	return set
 }
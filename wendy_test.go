@@ -1,12 +1,23 @@
 package wendy
 
 import (
+	"bytes"
+	"context"
 	"crypto/ed25519"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
 )
 
 func TestIsBlockedBy(t *testing.T) {
@@ -56,6 +67,185 @@ func TestIsBlockedBy(t *testing.T) {
 	})
 }
 
+func TestPriority(t *testing.T) {
+	t.Run("FullyAgree", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+		assert.Equal(t, -1, w.Priority(testTx1, testTx2))
+		assert.Equal(t, 1, w.Priority(testTx2, testTx1))
+		assert.Equal(t, -1, w.Priority(testTx1, testTx0), "testTx0 has not been voted")
+
+		txs := []Tx{testTx5, testTx3, testTx1, testTx4, testTx2}
+		sort.Slice(txs, func(i, j int) bool {
+			return w.Priority(txs[i], txs[j]) < 0
+		})
+		assert.Equal(t, []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}, txs)
+	})
+
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+		// 3 out of 5 nodes report tx1 before tx3 but quorum is 4.
+		assert.Equal(t, 0, w.Priority(testTx1, testTx3))
+		assert.Equal(t, 0, w.Priority(testTx3, testTx1))
+	})
+}
+
+func TestBeforeCount(t *testing.T) {
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: {testTx0, testTx1},
+			&pub1: {testTx0, testTx1},
+			&pub2: {testTx1, testTx0},
+			&pub3: {testTx0},
+		},
+	)
+
+	assert.Equal(t, 3, w.BeforeCount(testTx0, testTx1))
+	assert.Equal(t, 1, w.BeforeCount(testTx1, testTx0))
+	assert.Equal(t, 0, w.BeforeCount(testTx0, NewSimpleTx("other", "other").withLabel("other")))
+}
+
+func TestVotesUntilUnblocked(t *testing.T) {
+	w := New()
+	assert.Equal(t, -1, w.VotesUntilUnblocked(testTx0), "no validators")
+	assert.Equal(t, 0, w.VotesUntilBlocked(testTx0))
+
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	})
+	assert.Equal(t, 3, w.VotesUntilUnblocked(testTx0))
+
+	require.NoError(t, w.AddVotes(
+		NewVote(pub0, 0, testTx0),
+		NewVote(pub1, 0, testTx0),
+	))
+	assert.Equal(t, 1, w.VotesUntilUnblocked(testTx0))
+	assert.Equal(t, 0, w.VotesUntilBlocked(testTx0))
+
+	require.NoError(t, w.AddVotes(
+		NewVote(pub2, 0, testTx0),
+		NewVote(pub3, 0, testTx0),
+	))
+	assert.False(t, w.IsBlocked(testTx0))
+	assert.Equal(t, 0, w.VotesUntilUnblocked(testTx0))
+	assert.Equal(t, 2, w.VotesUntilBlocked(testTx0))
+}
+
+func TestCanCommit(t *testing.T) {
+	t.Run("FullyAgree", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+		assert.True(t, w.CanCommit([]Tx{testTx1}))
+		assert.True(t, w.CanCommit([]Tx{testTx1, testTx2}))
+		assert.False(t, w.CanCommit([]Tx{testTx2}), "testTx1 has priority over testTx2")
+		assert.False(t, w.CanCommit([]Tx{testTx1, testTx3}), "testTx2 has priority over testTx3")
+	})
+
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+		assert.False(t, w.CanCommit([]Tx{testTx1}), "testTx5 has priority over testTx1")
+		assert.False(t, w.CanCommit([]Tx{testTx1, testTx2, testTx3, testTx4}))
+		assert.True(t, w.CanCommit([]Tx{testTx1, testTx2, testTx3, testTx4, testTx5}))
+	})
+}
+
+func TestIsFinal(t *testing.T) {
+	t.Run("QuorumAgrees", func(t *testing.T) {
+		w := newTestWendy(t,
+			map[*Pubkey][]Tx{
+				&pub0: {testTx0, testTx1, testTx2},
+				&pub1: {testTx0, testTx1},
+				&pub2: {testTx0, testTx1},
+				&pub3: {testTx1, testTx0},
+			},
+		)
+		require.Equal(t, 3, w.Quorum())
+
+		assert.True(t, w.IsFinal(testTx0))
+		assert.True(t, w.IsFinal(testTx1))
+		assert.False(t, w.IsFinal(testTx2), "testTx2 is blocked")
+	})
+
+	t.Run("Undecided", func(t *testing.T) {
+		w := newTestWendy(t,
+			map[*Pubkey][]Tx{
+				&pub0: {testTx0, testTx1},
+				&pub1: {testTx0, testTx1},
+				&pub2: {testTx1, testTx0},
+				&pub3: {testTx1, testTx0},
+			},
+		)
+
+		require.False(t, w.IsBlocked(testTx0))
+		require.False(t, w.IsBlocked(testTx1))
+		assert.False(t, w.IsFinal(testTx0))
+		assert.False(t, w.IsFinal(testTx1))
+	})
+}
+
+func TestIsFinalWeighted(t *testing.T) {
+	w := New()
+	assert.False(t, w.IsFinalWeighted(testTx0), "no validators")
+
+	w.UpdateValidatorSetWeighted(map[ID]uint64{
+		ID(pub0.String()): 1,
+		ID(pub1.String()): 1,
+		ID(pub2.String()): 4,
+		ID(pub3.String()): 6,
+	})
+	// total weight is 12, quorum is floor(12 * 2/3) + 1
+	require.Equal(t, 9, w.Quorum())
+	require.Equal(t, 3, w.HonestMajority())
+
+	require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0), NewVote(pub1, 0, testTx0)))
+	assert.False(t, w.IsFinalWeighted(testTx0), "2 validators with 2of12")
+
+	vote := NewVote(pub2, 0, testTx1)
+	require.NoError(t, w.AddVotes(vote))
+	assert.False(t, w.IsFinalWeighted(testTx1), "a single validator with 4of12")
+
+	require.NoError(t, w.AddVotes(NewVote(pub3, 0, testTx2)))
+	assert.False(t, w.IsFinalWeighted(testTx2), "a single validator with 6of12")
+	assert.True(t, w.IsBlocked(testTx2))
+
+	require.NoError(t, w.AddVotes(NewVote(pub2, 1, testTx2).WithPrevHash(vote.Hash())))
+	assert.True(t, w.IsFinalWeighted(testTx2), "2 validators with 10of12")
+	assert.False(t, w.IsBlocked(testTx2))
+
+	t.Run("Halted", func(t *testing.T) {
+		w := New(WithMinValidators(4))
+		w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+		require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0), NewVote(pub1, 0, testTx0)))
+		assert.False(t, w.IsFinalWeighted(testTx0))
+		assert.Equal(t, -1, w.VotesUntilUnblocked(testTx0))
+	})
+}
+
+func TestExpireStalePeers(t *testing.T) {
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: {testTx0},
+			&pub1: {testTx0},
+			&pub2: {testTx0},
+		},
+	)
+	require.False(t, w.IsBlocked(testTx0))
+
+	assert.Empty(t, w.ExpireStalePeers(time.Now().Add(-time.Minute)))
+	require.False(t, w.IsBlocked(testTx0))
+
+	stale := w.ExpireStalePeers(time.Now().Add(time.Minute))
+	assert.ElementsMatch(t, []ID{ID(pub0.String()), ID(pub1.String()), ID(pub2.String())}, stale)
+	assert.True(t, w.IsBlocked(testTx0), "votes from stale peers should not be counted")
+	assert.Equal(t, 0, w.SeenCount(testTx0), "stale peers should not be counted")
+	assert.Equal(t, 3, w.ValidatorCount(), "stale peers should remain validators")
+
+	// voting again makes the peers count again.
+	for _, pub := range []Pubkey{pub0, pub1, pub2} {
+		_, err := w.AddVote(NewVote(pub, 2, testTx1))
+		require.NoError(t, err)
+	}
+	assert.False(t, w.IsBlocked(testTx0))
+}
+
 func TestVoteByHash(t *testing.T) {
 	var (
 		w    = New()
@@ -75,6 +265,70 @@ func TestVoteByHash(t *testing.T) {
 	assert.Nil(t, w.VoteByTxHash(testTx1.Hash()))
 }
 
+func TestAddVoteBatch(t *testing.T) {
+	w := New()
+
+	added, err := w.AddVoteBatch([]*Vote{testVote0, testVote1, testVote2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, added)
+
+	added, err = w.AddVoteBatch([]*Vote{testVote1, testVote2, testVote3})
+	require.NoError(t, err)
+	assert.Equal(t, 1, added, "duplicated votes should not be counted")
+}
+
+func TestPeerVotes(t *testing.T) {
+	w := New()
+	require.NoError(t, w.AddVotes(testVote2, testVote0, testVote1))
+
+	votes, ok := w.PeerVotes(testVote0.Key())
+	require.True(t, ok)
+	require.Len(t, votes, 3)
+	for i, vote := range []*Vote{testVote0, testVote1, testVote2} {
+		assert.Equal(t, vote.Hash(), votes[i].Hash())
+	}
+
+	// mutating the returned votes should not affect the internal state.
+	votes[0].Seq = 100
+	assert.Equal(t, uint64(0), testVote0.Seq)
+
+	_, ok = w.PeerVotes(ID(pub1.String()))
+	assert.False(t, ok)
+}
+
+func TestEquivocations(t *testing.T) {
+	w := New()
+	require.NoError(t, w.AddVotes(testVote0, testVote1))
+	assert.Empty(t, w.Equivocations())
+
+	conflicting := NewVote(pub0, 1, testTx2).WithPrevHash(testVote0.Hash())
+	added, err := w.AddVote(conflicting)
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	assert.Equal(t, map[ID][]EquivocationProof{
+		testVote0.Key(): {{First: testVote1, Second: conflicting}},
+	}, w.Equivocations())
+}
+
+func TestAddSignedVote(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+	w := New()
+
+	sv := NewSignedVote(priv, NewVote(Pubkey(pub), 0, testTx0))
+	added, err := w.AddSignedVote(sv)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	forged := NewSignedVote(priv, NewVote(pub0, 0, testTx1))
+	added, err = w.AddSignedVote(forged)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.ErrorIs(t, err, ErrBadSignature)
+	assert.False(t, added)
+	assert.Nil(t, w.VoteByTxHash(testTx1.Hash()))
+}
+
 func TestIsBlocked(t *testing.T) {
 	w := New()
 
@@ -91,6 +345,13 @@ func TestIsBlocked(t *testing.T) {
 
 	require.NoError(t, w.AddVotes(NewVote(pub2, 0, testTx0)))
 	require.False(t, w.IsBlocked(testTx0), "should be blocked with 3of4")
+	assert.Equal(t, 3, w.SeenCount(testTx0))
+	assert.Equal(t, 3, w.VoteCount(testTx0))
+
+	// votes from peers that are not validators are not counted.
+	require.NoError(t, w.AddVotes(NewVote(newRandPubkey(), 0, testTx0)))
+	assert.Equal(t, 3, w.SeenCount(testTx0))
+	assert.Equal(t, 3, w.VoteCount(testTx0))
 
 	t.Run("Gapped", func(t *testing.T) {
 		tx := NewSimpleTx("tx-gapped", "hash-gapped")
@@ -101,15 +362,395 @@ func TestIsBlocked(t *testing.T) {
 			NewVote(pub2, 2, tx),
 		))
 		require.True(t, w.IsBlocked(tx), "should be blocked if seq is gapped")
+		assert.Equal(t, 0, w.SeenCount(tx))
+		assert.Equal(t, 3, w.VoteCount(tx))
+
+		assert.Equal(t, map[ID][]uint64{
+			ID(pub0.String()): {1},
+			ID(pub1.String()): {1},
+			ID(pub2.String()): {1},
+		}, w.PeerGaps(""))
+	})
+}
+
+func TestEmptyValidatorSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(w *Wendy)
+	}{
+		{"New", func(w *Wendy) {}},
+		{"EmptyUpdate", func(w *Wendy) { w.UpdateValidatorSet(nil) }},
+		{"EmptyWeightedUpdate", func(w *Wendy) { w.UpdateValidatorSetWeighted(nil) }},
+		{"AllRemoved", func(w *Wendy) {
+			w.AddValidator(pub0.Bytes())
+			w.RemoveValidator(pub0.Bytes())
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := New()
+			test.setup(w)
+
+			assert.Equal(t, 0, w.Quorum())
+			assert.Equal(t, 0, w.HonestParties())
+			assert.Equal(t, 0, w.HonestMajority())
+
+			_, err := w.AddVote(NewVote(pub0, 0, testTx0))
+			require.NoError(t, err)
+			assert.True(t, w.IsBlocked(testTx0))
+			assert.True(t, w.IsBlockedBy(testTx0, testTx1))
+		})
+	}
+}
+
+func TestUpdateValidatorSetDiff(t *testing.T) {
+	w := New()
+
+	diff := w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+	assert.Equal(t, []Validator{pub0.Bytes(), pub1.Bytes()}, diff.Added)
+	assert.Empty(t, diff.Removed)
+
+	diff = w.UpdateValidatorSet([]Validator{pub1.Bytes(), pub2.Bytes()})
+	assert.Equal(t, []Validator{pub2.Bytes()}, diff.Added)
+	assert.Equal(t, []Validator{pub0.Bytes()}, diff.Removed)
+
+	diff, err := w.UpdateValidatorSetWeighted(map[ID]uint64{
+		ID(pub1.String()): 2,
+		ID(pub2.String()): 1,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, diff.Added, "weight changes are not part of the diff")
+	assert.Empty(t, diff.Removed)
+}
+
+func TestUpdateValidatorSetDuplicates(t *testing.T) {
+	vs := []Validator{pub0.Bytes(), pub1.Bytes(), pub0.Bytes(), {}, pub2.Bytes()}
+
+	t.Run("Ignored", func(t *testing.T) {
+		w := New()
+		diff := w.UpdateValidatorSet(vs)
+		assert.Equal(t, []Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()}, diff.Added)
+		assert.Equal(t, 3, w.ValidatorCount())
+		assert.Equal(t, 3, w.Quorum())
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		w := New()
+		_, err := w.UpdateValidatorSetStrict(vs)
+		assert.ErrorIs(t, err, ErrDuplicateValidator)
+		assert.Equal(t, 0, w.ValidatorCount(), "the validator set is not updated")
+
+		_, err = w.UpdateValidatorSetStrict([]Validator{pub0.Bytes(), {}})
+		assert.ErrorIs(t, err, ErrEmptyValidator)
+
+		diff, err := w.UpdateValidatorSetStrict([]Validator{pub0.Bytes(), pub1.Bytes()})
+		require.NoError(t, err)
+		assert.Len(t, diff.Added, 2)
+		assert.Equal(t, 2, w.ValidatorCount())
+	})
+}
+
+func TestUpdateValidatorSetWeighted(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSetWeighted(map[ID]uint64{
+		ID(pub0.String()): 5,
+		ID(pub1.String()): 1,
+		ID(pub2.String()): 1,
+		ID(pub3.String()): 1,
+	})
+	// total weight is 8, quorum is floor(8 * 2/3) + 1
+	require.Equal(t, 6, w.Quorum())
+	require.Equal(t, 4, w.ValidatorCount())
+	require.Equal(t, 6, w.HonestParties())
+	require.Equal(t, 2, w.HonestMajority())
+
+	require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0)))
+	require.True(t, w.IsBlocked(testTx0), "should be blocked with 5of8")
+
+	// peers that are not validators have no weight.
+	require.NoError(t, w.AddVotes(NewVote(newRandPubkey(), 0, testTx0)))
+	require.True(t, w.IsBlocked(testTx0), "should be blocked with 5of8 and a non validator")
+
+	require.NoError(t, w.AddVotes(NewVote(pub1, 0, testTx0)))
+	require.False(t, w.IsBlocked(testTx0), "should NOT be blocked with 6of8")
+
+	t.Run("IsBlockedBy", func(t *testing.T) {
+		w := New()
+		w.UpdateValidatorSetWeighted(map[ID]uint64{
+			ID(pub0.String()): 7,
+			ID(pub1.String()): 1,
+			ID(pub2.String()): 1,
+			ID(pub3.String()): 1,
+		})
+		// total weight is 10, quorum is floor(10 * 2/3) + 1
+		require.Equal(t, 7, w.Quorum())
+
+		// pub0 reports testTx0 first, the rest testTx1 first.
+		vote := func(pub Pubkey, first, second Tx) {
+			v := NewVote(pub, 0, first)
+			require.NoError(t, w.AddVotes(v, NewVote(pub, 1, second).WithPrevHash(v.Hash())))
+		}
+		vote(pub0, testTx0, testTx1)
+		for _, pub := range []Pubkey{pub1, pub2, pub3} {
+			vote(pub, testTx1, testTx0)
+		}
+
+		assert.False(t, w.IsBlockedBy(testTx0, testTx1), "pub0 stake alone reaches the quorum")
+		assert.True(t, w.IsBlockedBy(testTx1, testTx0), "3 validators out of 4 don't reach the quorum")
+	})
+
+	t.Run("Unweighted", func(t *testing.T) {
+		w := New()
+		w.UpdateValidatorSet([]Validator{
+			pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+		})
+		assert.Equal(t, 3, w.HonestParties())
+		assert.Equal(t, 1, w.HonestMajority())
+	})
+
+	t.Run("CanonicalIDs", func(t *testing.T) {
+		w := New()
+		weights := make(map[ID]uint64)
+		for _, pub := range []Pubkey{pub0, pub1, pub2, pub3} {
+			// uppercase and without the 0x prefix.
+			weights[ID(strings.ToUpper(hex.EncodeToString(pub)))] = 1
+		}
+		_, err := w.UpdateValidatorSetWeighted(weights)
+		require.NoError(t, err)
+		require.Equal(t, 4, w.ValidatorCount())
+
+		for _, pub := range []Pubkey{pub0, pub1, pub2, pub3} {
+			require.NoError(t, w.AddVotes(NewVote(pub, 0, testTx0)))
+		}
+		assert.False(t, w.IsBlocked(testTx0), "every validator voted")
+	})
+
+	t.Run("InvalidIDs", func(t *testing.T) {
+		w := New()
+		for _, test := range []struct {
+			weights map[ID]uint64
+			err     error
+		}{
+			{map[ID]uint64{"0xzz": 1}, ErrInvalidValidatorID},
+			{map[ID]uint64{"0x": 1}, ErrEmptyValidator},
+			{map[ID]uint64{"0xab": 1, "AB": 2}, ErrDuplicateValidator},
+		} {
+			_, err := w.UpdateValidatorSetWeighted(test.weights)
+			assert.ErrorIs(t, err, test.err, "weights %v", test.weights)
+		}
+		assert.Equal(t, 0, w.ValidatorCount(), "the validator set is not updated")
+	})
+}
+
+func TestWaitUntilUnblocked(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	})
+	require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0)))
+
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, w.WaitUntilUnblocked(ctx, testTx0), context.DeadlineExceeded)
+	})
+
+	t.Run("Unblocked", func(t *testing.T) {
+		errCh := make(chan error)
+		go func() {
+			errCh <- w.WaitUntilUnblocked(context.Background(), testTx0)
+		}()
+
+		require.NoError(t, w.AddVotes(NewVote(pub1, 0, testTx0)))
+		select {
+		case <-errCh:
+			t.Fatal("should still be blocked with 2of4")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		require.NoError(t, w.AddVotes(NewVote(pub2, 0, testTx0)))
+		select {
+		case err := <-errCh:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("should be unblocked with 3of4")
+		}
+	})
+}
+
+func TestOnUnblock(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	})
+	w.AddTx(testTx0)
+
+	var unblocked []Tx
+	w.OnUnblock(func(tx Tx) {
+		// calling Wendy from the callback should not deadlock.
+		assert.False(t, w.IsBlocked(tx))
+		unblocked = append(unblocked, tx)
+	})
+
+	require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0), NewVote(pub1, 0, testTx0)))
+	assert.Empty(t, unblocked, "2of4 should not unblock")
+
+	_, err := w.AddVote(NewVote(pub2, 0, testTx0))
+	require.NoError(t, err)
+	assert.Equal(t, []Tx{testTx0}, unblocked)
+
+	_, err = w.AddVote(NewVote(pub3, 0, testTx0))
+	require.NoError(t, err)
+	assert.Equal(t, []Tx{testTx0}, unblocked, "should be notified only once")
+}
+
+func TestBlockedTxs(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	})
+	w.AddTx(testTx0)
+	w.AddTx(testTx1)
+
+	// testTx0 is seen by 3of4, testTx1 only by 1of4.
+	require.NoError(t, w.AddVotes(
+		NewVote(pub0, 0, testTx0),
+		NewVote(pub1, 0, testTx0),
+		NewVote(pub2, 0, testTx0),
+		NewVote(pub3, 0, testTx1),
+	))
+
+	assert.Equal(t, []Tx{testTx1}, w.BlockedTxs())
+	assert.Equal(t, []Tx{testTx0}, w.UnblockedTxs())
+}
+
+func TestAddRemoveValidator(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(),
+	})
+	require.NoError(t, w.AddVotes(
+		NewVote(pub0, 0, testTx0),
+		NewVote(pub1, 0, testTx0),
+	))
+	require.Equal(t, 3, w.Quorum())
+	require.True(t, w.IsBlocked(testTx0))
+
+	require.False(t, w.RemoveValidator(pub3.Bytes()), "pub3 is not a validator")
+	require.True(t, w.RemoveValidator(pub2.Bytes()))
+	assert.Equal(t, 2, w.ValidatorCount())
+	assert.Equal(t, 2, w.Quorum())
+	assert.False(t, w.IsBlocked(testTx0), "votes from pub0 and pub1 should be preserved")
+
+	require.False(t, w.AddValidator(pub0.Bytes()), "pub0 is already a validator")
+	require.True(t, w.AddValidator(pub3.Bytes()))
+	assert.Equal(t, 3, w.ValidatorCount())
+	assert.Equal(t, 3, w.Quorum())
+	assert.True(t, w.IsBlocked(testTx0))
+}
+
+func TestRekeyValidator(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+	vote0 := NewVote(pub0, 0, testTx0)
+	require.NoError(t, w.AddVotes(vote0, NewVote(pub1, 0, testTx0)))
+	require.False(t, w.IsBlocked(testTx0))
+
+	require.False(t, w.RekeyValidator(pub2.Bytes(), pub3.Bytes()), "pub2 is not a validator")
+	require.False(t, w.RekeyValidator(pub0.Bytes(), pub1.Bytes()), "pub1 is already a validator")
+	require.True(t, w.RekeyValidator(pub0.Bytes(), pub2.Bytes()))
+
+	assert.Equal(t, 2, w.ValidatorCount())
+	assert.Equal(t, 2, w.Quorum())
+	assert.False(t, w.IsBlocked(testTx0), "votes are migrated to the new key")
+	_, ok := w.PeerVotes(ID(pub0.String()))
+	assert.False(t, ok)
+	assert.Equal(t, Pubkey(pub0), vote0.Pubkey, "added votes are not modified")
+
+	votes, ok := w.PeerVotes(ID(pub2.String()))
+	require.True(t, ok)
+	require.Len(t, votes, 1)
+	assert.Equal(t, Pubkey(pub2), votes[0].Pubkey)
+
+	// the new key keeps the vote chain.
+	ok, err := w.AddVote(NewVote(pub2, 1, testTx1).WithPrevHash(vote0.Hash()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestWithSequenceWindow(t *testing.T) {
+	w := New(WithSequenceWindow(10))
+	w.UpdateValidatorSet([]Validator{pub0.Bytes()})
+
+	ok, err := w.AddVote(NewVote(pub0, 11, testTx0))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, w.VoteByTxHash(testTx0.Hash()), "rejected votes should not be registered")
+
+	ok, err = w.AddVote(NewVote(pub0, 10, testTx0))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestWithQuorumFraction(t *testing.T) {
+	w := New(WithQuorumFraction(0.75))
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
 	})
+	// floor(4 * 0.75) + 1
+	assert.Equal(t, 4, w.Quorum())
+
+	for _, f := range []float64{0, 0.5, 1, 1.5} {
+		assert.Panics(t, func() { WithQuorumFraction(f) }, "fraction %v", f)
+	}
 }
 
-func newWendyFromTxsMap(t *testing.T, txsMap map[ID][]Tx) *Wendy {
+func TestStats(t *testing.T) {
 	w := New()
+	w.UpdateValidatorSet([]Validator{
+		pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+	})
+	w.AddTx(testTx0)
+	w.AddTx(testTx1)
+	require.NoError(t, w.AddVotes(
+		NewVote(pub0, 0, testTx0),
+		NewVote(pub1, 0, testTx0),
+		NewVote(pub2, 0, testTx0),
+	))
+
+	assert.Equal(t, Stats{
+		NumTxs:        2,
+		NumVotes:      1,
+		NumPeers:      4,
+		Quorum:        3,
+		NumValidators: 4,
+		NumBlocked:    1,
+	}, w.Stats())
+
+	t.Run("DuplicateVotes", func(t *testing.T) {
+		vote := NewVote(pub3, 0, testTx1)
+		for i := 0; i < 3; i++ {
+			_, err := w.AddVote(vote)
+			require.NoError(t, err)
+		}
+
+		// equivocations are not duplicates.
+		added, err := w.AddVote(NewVote(pub3, 0, testTx0))
+		require.NoError(t, err)
+		require.False(t, added)
+
+		assert.Equal(t, uint64(2), w.DuplicateVoteCount())
+		assert.Equal(t, uint64(2), w.Stats().NumDuplicateVotes)
+	})
+}
+
+func newWendyFromTxsMap(t *testing.T, txsMap map[ID][]Tx, opts ...Option) *Wendy {
+	w := New(opts...)
 
 	var nodes []Validator
 	for node := range txsMap {
-		nodes = append(nodes, Validator(node))
+		nodes = append(nodes, Validator(NewPubkeyFromID(node)))
 	}
 	w.UpdateValidatorSet(nodes)
 
@@ -267,38 +908,1130 @@ func TestNewBlock(t *testing.T) {
 		}
 		assert.LessOrEqual(t, size, 10)
 	})
-}
 
-func TestAddBlock(t *testing.T) {
-	allTxs := []Tx{testTx0, testTx1, testTx2, testTx3, testTx4}
-	w := newWendyFromTxsMap(t,
-		map[ID][]Tx{
-			"0x00": allTxs,
-		},
-	)
+	t.Run("WithMinTxs", func(t *testing.T) {
+		block := w.NewBlockWithOptions(NewBlockOptions{MinTxs: len(allTxs)})
+		assert.Equal(t, allTxs, block.Txs)
 
-	// a new block is added with these txs, thus they should NOT be in the
-	// NewBlock()
-	block := &Block{
-		Txs: []Tx{testTx0, testTx4},
-	}
-	w.AddBlock(block)
+		block = w.NewBlockWithOptions(NewBlockOptions{MinTxs: len(allTxs) + 1})
+		assert.Empty(t, block.Txs)
 
-	newBlock := w.NewBlock()
-	expectedTxs := []Tx{testTx1, testTx2, testTx3}
-	require.Equal(t, expectedTxs, newBlock.Txs)
-}
+		w := New()
+		w.AddTx(testTx0)
+		block = w.NewBlockWithOptions(NewBlockOptions{MinTxs: 1})
+		assert.Empty(t, block.Txs, "blocked txs should not count")
+	})
 
-func TestVoteSigning(t *testing.T) {
-	pub, priv, err := ed25519.GenerateKey(Rand)
-	require.NoError(t, err)
+	t.Run("WithTxLimitAndMaxBlockSize", func(t *testing.T) {
+		// every tx is 3 bytes long.
+		tests := []struct {
+			name     string
+			opts     NewBlockOptions
+			expected []Tx
+		}{
+			{
+				name:     "TxLimitFirst",
+				opts:     NewBlockOptions{TxLimit: 2, MaxBlockSize: 10},
+				expected: []Tx{testTx0, testTx1},
+			},
+			{
+				name:     "MaxBlockSizeFirst",
+				opts:     NewBlockOptions{TxLimit: 4, MaxBlockSize: 10},
+				expected: []Tx{testTx0, testTx1, testTx2},
+			},
+			{
+				name:     "PerTxOverhead",
+				opts:     NewBlockOptions{MaxBlockSize: 10, PerTxOverhead: 2},
+				expected: []Tx{testTx0, testTx1},
+			},
+		}
 
-	key := Pubkey(pub)
-	vote := NewVote(key, 0, testTx0)
-	sv := NewSignedVote(priv, vote)
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				block := w.NewBlockWithOptions(test.opts)
+				assert.Equal(t, test.expected, block.Txs)
+			})
+		}
+	})
 
-	require.True(t, sv.Verify())
+	t.Run("WithLabelFilter", func(t *testing.T) {
+		var (
+			txA0 = NewSimpleTx("txA0", "hA0").withLabel("A")
+			txA1 = NewSimpleTx("txA1", "hA1").withLabel("A")
+			txB0 = NewSimpleTx("txB0", "hB0").withLabel("B")
+			txB1 = NewSimpleTx("txB1", "hB1").withLabel("B")
+		)
+		w := New()
+		w.UpdateValidatorSet([]Validator{pub0.Bytes()})
+		for _, tx := range []Tx{txA0, txB0, txA1, txB1} {
+			w.AddTx(tx)
+		}
+		voteA0, voteB0 := NewVote(pub0, 0, txA0), NewVote(pub0, 0, txB0)
+		require.NoError(t, w.AddVotes(
+			voteA0,
+			voteB0,
+			NewVote(pub0, 1, txA1).WithPrevHash(voteA0.Hash()),
+			NewVote(pub0, 1, txB1).WithPrevHash(voteB0.Hash()),
+		))
 
-	sv.Data.Pubkey = pub0
+		block := w.NewBlockWithOptions(NewBlockOptions{
+			TxLimit:     1,
+			LabelFilter: func(label string) bool { return label == "B" },
+		})
+		assert.Equal(t, []Tx{txB0}, block.Txs)
+	})
+
+	t.Run("PrefersMostDependants", func(t *testing.T) {
+		// testTx4 blocks all the txs and testTx2 blocks all but testTx4.
+		order := []Tx{testTx4, testTx2, testTx3, testTx0, testTx1}
+		w := newTestWendy(t,
+			map[*Pubkey][]Tx{
+				&pub0: order, &pub1: order, &pub2: order,
+			},
+		)
+
+		block := w.NewBlockWithOptions(NewBlockOptions{TxLimit: 2})
+		assert.Equal(t, []Tx{testTx2, testTx4}, block.Txs)
+	})
+}
+
+func TestNewBlockExclude(t *testing.T) {
+	w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+
+	block := w.NewBlockWithOptions(NewBlockOptions{
+		Exclude: map[Hash]bool{testTx3.Hash(): true},
+	})
+	assert.Equal(t, []Tx{testTx1, testTx2, testTx4, testTx5}, block.Txs)
+
+	// excluded txs don't count towards the limits.
+	block = w.NewBlockWithOptions(NewBlockOptions{
+		TxLimit: 2,
+		Exclude: map[Hash]bool{testTx1.Hash(): true},
+	})
+	assert.Equal(t, []Tx{testTx2, testTx3}, block.Txs)
+}
+
+func TestNewBlockMustInclude(t *testing.T) {
+	w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+
+	// testTx4 is blocked by testTx1, testTx2 and testTx3, which are included
+	// along with it despite the limit.
+	block := w.NewBlockWithOptions(NewBlockOptions{
+		TxLimit:     2,
+		MustInclude: []Hash{testTx4.Hash()},
+	})
+	assert.Equal(t, []Tx{testTx1, testTx2, testTx3, testTx4}, block.Txs)
+
+	// the rest of the block is filled with the space left.
+	block = w.NewBlockWithOptions(NewBlockOptions{
+		TxLimit:     3,
+		MustInclude: []Hash{testTx2.Hash()},
+	})
+	assert.Equal(t, []Tx{testTx1, testTx2, testTx3}, block.Txs)
+
+	// must-include txs bypass the filter, and unknown hashes are ignored.
+	block = w.NewBlockWithOptions(NewBlockOptions{
+		LabelFilter: func(string) bool { return false },
+		MustInclude: []Hash{testTx1.Hash(), testTx0.Hash()},
+	})
+	assert.Equal(t, []Tx{testTx1}, block.Txs)
+
+	block = w.NewBlockWithOptions(NewBlockOptions{
+		MinTxs:      10,
+		MustInclude: []Hash{testTx1.Hash()},
+	})
+	assert.Equal(t, []Tx{testTx1}, block.Txs, "MinTxs only applies to the rest")
+
+	t.Run("Blocked", func(t *testing.T) {
+		w := newTestWendy(t,
+			map[*Pubkey][]Tx{
+				&pub0: {testTx0, testTx1, testTx2},
+				&pub1: {testTx0, testTx1},
+				&pub2: {testTx0, testTx1},
+			},
+		)
+		require.True(t, w.IsBlocked(testTx2))
+
+		block := w.DrainUnblocked(NewBlockOptions{
+			MustInclude: []Hash{testTx2.Hash()},
+		})
+		assert.Equal(t, []Tx{testTx0, testTx1, testTx2}, block.Txs)
+		assert.Empty(t, w.NewBlock().Txs)
+	})
+}
+
+func TestFairnessTimeout(t *testing.T) {
+	w := newWendyFromTxsMap(t, fairnessLoopTxsMap, WithFairnessTimeout(time.Minute))
+	opts := NewBlockOptions{TxLimit: 2}
+
+	require.Len(t, w.eligibleAt, 5, "all the txs are unblocked")
+	assert.Empty(t, w.NewBlockWithOptions(opts).Txs, "the loop doesn't fit")
+
+	// the txs have been stuck behind the loop for longer than the timeout.
+	for hash := range w.eligibleAt {
+		w.eligibleAt[hash] = time.Now().Add(-time.Hour)
+	}
+	block := w.NewBlockWithOptions(opts)
+	assert.Equal(t, []Tx{testTx1, testTx2}, block.Txs)
+	assert.Equal(t, map[Hash]bool{testTx1.Hash(): true, testTx2.Hash(): true}, block.Forced)
+	assert.Nil(t, block.Cyclic)
+
+	w.CommitBlock(*block)
+	assert.Len(t, w.eligibleAt, 3)
+
+	t.Run("Strict", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+		assert.Empty(t, w.eligibleAt, "not tracked without a timeout")
+
+		block := w.NewBlock()
+		assert.Len(t, block.Txs, 5)
+		assert.Nil(t, block.Forced)
+	})
+}
+
+func TestNewBlockPreferOlderRounds(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+
+	// txs with different labels don't block each other, so all of them are
+	// selectable.
+	txs := []Tx{
+		NewSimpleTx("tx-a", "hash-a").withLabel("a"),
+		NewSimpleTx("tx-b", "hash-b").withLabel("b"),
+		NewSimpleTx("tx-c", "hash-c").withLabel("c"),
+	}
+	sortTxs(txs)
+	for _, pub := range []Pubkey{pub0, pub1, pub2} {
+		for _, tx := range txs {
+			_, err := w.AddVote(NewVote(pub, 0, tx))
+			require.NoError(t, err)
+		}
+	}
+	require.True(t, w.AddTxWithRound(txs[0], 5))
+	require.True(t, w.AddTxWithRound(txs[1], 2))
+	require.True(t, w.AddTx(txs[2]))
+	require.False(t, w.AddTxWithRound(txs[2], 1), "already added")
+
+	block := w.NewBlockWithOptions(NewBlockOptions{TxLimit: 1})
+	assert.Equal(t, []Tx{txs[0]}, block.Txs, "hash order by default")
+
+	block = w.NewBlockWithOptions(NewBlockOptions{TxLimit: 1, PreferOlderRounds: true})
+	assert.Equal(t, []Tx{txs[1]}, block.Txs)
+
+	// txs without round go last, the block is still sorted by hash.
+	block = w.NewBlockWithOptions(NewBlockOptions{TxLimit: 2, PreferOlderRounds: true})
+	assert.Equal(t, []Tx{txs[0], txs[1]}, block.Txs)
+
+	w.CommitBlock(*block)
+	assert.Empty(t, w.rounds)
+}
+
+func TestNewBlockPriority(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+
+	// the fee is the first byte of the tx, txs with different labels don't
+	// block each other.
+	txs := []Tx{
+		NewSimpleTx("\x01", "hash-a").withLabel("a"),
+		NewSimpleTx("\x03", "hash-b").withLabel("b"),
+		NewSimpleTx("\x02", "hash-c").withLabel("c"),
+	}
+	for _, pub := range []Pubkey{pub0, pub1, pub2} {
+		for _, tx := range txs {
+			_, err := w.AddVote(NewVote(pub, 0, tx))
+			require.NoError(t, err)
+		}
+	}
+	for _, tx := range txs {
+		w.AddTx(tx)
+	}
+
+	byFee := func(a, b Tx) bool { return a.Bytes()[0] > b.Bytes()[0] }
+	opts := NewBlockOptions{TxLimit: 2, Priority: byFee}
+	block := w.NewBlockWithOptions(opts)
+	assert.Equal(t, []Tx{txs[1], txs[2]}, block.Txs, "the highest fees, sorted by hash")
+
+	numTxs, _ := w.BlockingSet().BlockStats(opts)
+	assert.Equal(t, 2, numTxs)
+
+	block = w.NewBlockWithOptions(NewBlockOptions{TxLimit: 2})
+	assert.Equal(t, []Tx{txs[0], txs[1]}, block.Txs, "hash order by default")
+}
+
+func TestNewBlockIsDeterministic(t *testing.T) {
+	var blocks []*Block
+	for i := 0; i < 20; i++ {
+		w := newWendyFromTxsMap(t,
+			map[ID][]Tx{
+				"0x00": allTestTxs,
+			},
+		)
+
+		// txs are added in different order on every iteration.
+		txs := make([]Tx, len(allTestTxs))
+		copy(txs, allTestTxs)
+		Rand.Shuffle(len(txs), func(i, j int) {
+			txs[i], txs[j] = txs[j], txs[i]
+		})
+		w.txs = NewTxs(txs...)
+
+		blocks = append(blocks, w.NewBlockWithOptions(
+			NewBlockOptions{TxLimit: 3},
+		))
+	}
+
+	for _, block := range blocks {
+		require.Equal(t, []Tx{testTx0, testTx1, testTx2}, block.Txs)
+	}
+}
+
+func TestNewBlockCyclic(t *testing.T) {
+	t.Run("FairnessLoop", func(t *testing.T) {
+		allTxs := []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}
+		for i := 0; i < 10; i++ {
+			w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+
+			block := w.NewBlock()
+			assert.Equal(t, allTxs, block.Txs)
+			for _, tx := range allTxs {
+				assert.True(t, block.Cyclic[tx.Hash()])
+			}
+		}
+	})
+
+	t.Run("FullyAgree", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fullyAgreeTxsMap)
+		assert.Nil(t, w.NewBlock().Cyclic)
+	})
+}
+
+func TestNewBlockTieBreakByArrivalTime(t *testing.T) {
+	w := newWendyFromTxsMap(t, fairnessLoopTxsMap)
+	for _, tx := range []Tx{testTx1, testTx2, testTx3, testTx4, testTx5} {
+		require.False(t, w.arrivals[tx.Hash()].IsZero())
+	}
+
+	// the loop is ordered by arrival time, txs without arrival time go last.
+	now := time.Now()
+	w.arrivals = map[Hash]time.Time{
+		testTx4.Hash(): now,
+		testTx2.Hash(): now.Add(time.Second),
+		testTx5.Hash(): now.Add(2 * time.Second),
+	}
+	block := w.NewBlockWithOptions(NewBlockOptions{TieBreak: ByArrivalTime})
+	assert.Equal(t, []Tx{testTx4, testTx2, testTx5, testTx1, testTx3}, block.Txs)
+
+	// the default is still hash order.
+	block = w.NewBlock()
+	assert.Equal(t, []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}, block.Txs)
+}
+
+func TestNewBlockTieBreakRandom(t *testing.T) {
+	assert.Equal(t, crand.Reader, New().rand)
+
+	// the same seed produces the same order.
+	var blocks [][]Tx
+	for i := 0; i < 2; i++ {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap, WithRand(rand.New(rand.NewSource(42))))
+		block := w.NewBlockWithOptions(NewBlockOptions{TieBreak: Random})
+		assert.ElementsMatch(t, []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}, block.Txs)
+		blocks = append(blocks, block.Txs)
+	}
+	assert.Equal(t, blocks[0], blocks[1])
+	assert.NotEqual(t, []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}, blocks[0], "the seed shuffles the loop")
+
+	t.Run("FailingSource", func(t *testing.T) {
+		w := newWendyFromTxsMap(t, fairnessLoopTxsMap, WithRand(bytes.NewReader(nil)))
+		block := w.NewBlockWithOptions(NewBlockOptions{TieBreak: Random})
+		assert.Equal(t, []Tx{testTx1, testTx2, testTx3, testTx4, testTx5}, block.Txs, "hash order")
+	})
+}
+
+func TestAddVoteSetsReceivedAt(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+
+	vote0 := NewVote(pub0, 0, testTx0)
+	_, err := w.AddVote(vote0)
+	require.NoError(t, err)
+	require.False(t, vote0.ReceivedAt.IsZero())
+
+	// the arrival time is the earliest vote.
+	vote1 := NewVote(pub1, 0, testTx0)
+	_, err = w.AddVote(vote1)
+	require.NoError(t, err)
+	assert.Equal(t, vote0.ReceivedAt, w.arrivals[testTx0.Hash()])
+
+	// ReceivedAt is not part of the vote hash.
+	hash := vote0.Hash()
+	vote0.ReceivedAt = time.Time{}
+	assert.Equal(t, hash, vote0.Hash())
+}
+
+func TestNewBlockWithAddBlock(t *testing.T) {
+	allTxs := []Tx{testTx0, testTx1, testTx2}
+	w := newWendyFromTxsMap(t,
+		map[ID][]Tx{
+			"0x00": allTxs,
+		},
+	)
+
+	block := w.NewBlockWithOptions(NewBlockOptions{TxLimit: 2, AddBlock: true})
+	assert.Equal(t, []Tx{testTx0, testTx1}, block.Txs)
+	assert.Equal(t, []Tx{testTx2}, w.NewBlock().Txs)
+}
+
+func TestDrainUnblocked(t *testing.T) {
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: {testTx0, testTx1, testTx2},
+			&pub1: {testTx0, testTx1},
+			&pub2: {testTx0, testTx1},
+		},
+	)
+	require.True(t, w.IsBlocked(testTx2))
+
+	block := w.DrainUnblocked(NewBlockOptions{})
+	assert.Equal(t, []Tx{testTx0, testTx1}, block.Txs)
+	assert.Nil(t, w.VoteByTxHash(testTx0.Hash()))
+	assert.Equal(t, []Tx{testTx2}, w.NewBlock().Txs)
+
+	assert.Empty(t, w.DrainUnblocked(NewBlockOptions{}).Txs, "testTx2 is still blocked")
+}
+
+func TestValidateBlock(t *testing.T) {
+	w := New()
+	w.AddTx(testTx0)
+	w.AddTx(testTx1)
+
+	assert.NoError(t, w.ValidateBlock(Block{Txs: []Tx{testTx0, testTx1}}))
+	assert.NoError(t, w.ValidateBlock(Block{}))
+
+	err := w.ValidateBlock(Block{Txs: []Tx{testTx0, testTx2, testTx3}})
+	assert.ErrorIs(t, err, ErrUnknownTx)
+	hash := testTx2.Hash()
+	assert.Contains(t, err.Error(), hex.EncodeToString(hash[:]), "the first unknown tx")
+}
+
+func TestAddBlock(t *testing.T) {
+	allTxs := []Tx{testTx0, testTx1, testTx2, testTx3, testTx4}
+	w := newWendyFromTxsMap(t,
+		map[ID][]Tx{
+			"0x00": allTxs,
+		},
+	)
+
+	// a new block is added with these txs, thus they should NOT be in the
+	// NewBlock()
+	block := &Block{
+		Txs: []Tx{testTx0, testTx4},
+	}
+	w.AddBlock(block)
+
+	newBlock := w.NewBlock()
+	expectedTxs := []Tx{testTx1, testTx2, testTx3}
+	require.Equal(t, expectedTxs, newBlock.Txs)
+}
+
+func TestRemoveTx(t *testing.T) {
+	allTxs := []Tx{testTx0, testTx1, testTx2}
+	w := newWendyFromTxsMap(t,
+		map[ID][]Tx{
+			"0x00": allTxs,
+		},
+	)
+
+	require.True(t, w.RemoveTx(testTx1.Hash()))
+	require.False(t, w.RemoveTx(testTx1.Hash()), "should return false on unknown txs")
+
+	assert.Nil(t, w.VoteByTxHash(testTx1.Hash()))
+	assert.Equal(t, []Tx{testTx0, testTx2}, w.NewBlock().Txs)
+}
+
+func TestEvictExpired(t *testing.T) {
+	w := newWendyFromTxsMap(t,
+		map[ID][]Tx{
+			"0x00": {testTx0, testTx1},
+		},
+	)
+
+	now := time.Now()
+	require.True(t, w.AddTxWithDeadline(testTx2, now.Add(-time.Second)))
+	require.True(t, w.AddTxWithDeadline(testTx3, now.Add(time.Second)))
+	require.False(t, w.AddTxWithDeadline(testTx3, now), "should return false on duplicated txs")
+
+	assert.Equal(t, 1, w.EvictExpired(now))
+	assert.Equal(t, 0, w.EvictExpired(now), "should not evict twice")
+
+	var txs []Tx
+	w.RangeTxs(func(tx Tx) bool {
+		txs = append(txs, tx)
+		return true
+	})
+	assert.Equal(t, []Tx{testTx0, testTx1, testTx3}, txs)
+
+	assert.Equal(t, 1, w.EvictExpired(now.Add(time.Minute)))
+	assert.Equal(t, 0, w.EvictExpired(now.Add(time.Hour)), "txs without deadline are never evicted")
+}
+
+func TestSetLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := New()
+	w.SetLogger(log.NewTMLogger(buf))
+
+	w.UpdateValidatorSet([]Validator{pub0.Bytes()})
+	w.AddTx(testTx0)
+	_, err := w.AddVote(NewVote(pub0, 0, testTx0))
+	require.NoError(t, err)
+	w.CommitBlock(Block{Txs: []Tx{testTx0}})
+
+	out := buf.String()
+	assert.Contains(t, out, "Updating validator set")
+	assert.Contains(t, out, "Adding vote")
+	assert.Contains(t, out, "Committing block")
+}
+
+// runConcurrently runs all the fns on their own goroutine and waits for
+// all of them to return.
+func runConcurrently(fns ...func()) {
+	var wg sync.WaitGroup
+	wg.Add(len(fns))
+	for _, fn := range fns {
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentAccess is meant to be run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	pubs := []Pubkey{pub0, pub1, pub2, pub3}
+	labels := []string{"", "label-a", "label-b"}
+
+	w := New()
+	var vs []Validator
+	for _, pub := range pubs {
+		vs = append(vs, pub.Bytes())
+	}
+	w.UpdateValidatorSet(vs)
+
+	var txs []Tx
+	for i := 0; i < 90; i++ {
+		tx := NewSimpleTx(fmt.Sprintf("tx%d", i), fmt.Sprintf("hash%d", i))
+		txs = append(txs, tx.withLabel(labels[i%len(labels)]))
+	}
+
+	var fns []func()
+	for _, pub := range pubs {
+		// votes are linked per label.
+		var votes []*Vote
+		prevs := make(map[string]*Vote)
+		seqs := make(map[string]uint64)
+		for _, tx := range txs {
+			label := tx.Label()
+			vote := NewVote(pub, seqs[label], tx)
+			if prev := prevs[label]; prev != nil {
+				vote.WithPrevHash(prev.Hash())
+			}
+			votes = append(votes, vote)
+			prevs[label] = vote
+			seqs[label]++
+		}
+
+		fns = append(fns, func() {
+			for i, vote := range votes {
+				w.AddTx(txs[i])
+				_, err := w.AddVote(vote)
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	fns = append(fns,
+		func() {
+			for i := 0; i < len(txs); i += 10 {
+				w.CommitBlock(Block{Txs: txs[i : i+5]})
+			}
+		},
+		func() {
+			for _, tx := range txs {
+				w.IsBlocked(tx)
+				w.SeenCount(tx)
+			}
+		},
+		func() {
+			for range txs {
+				w.NewBlock()
+				w.BlockingSetCached()
+				w.PeerGaps("label-a")
+			}
+		},
+	)
+
+	// readers querying labels no peer has voted for.
+	for i := 0; i < 2; i++ {
+		fns = append(fns, func() {
+			for j := range txs {
+				tx := NewSimpleTx("unvoted", "unvoted").withLabel(fmt.Sprintf("unvoted-%d", j))
+				w.IsBlocked(tx)
+				w.VoteCount(tx)
+			}
+		})
+	}
+
+	runConcurrently(fns...)
+}
+
+// TestConcurrentValidatorSetUpdates is meant to be run with -race, the
+// validator set, its weights and the quorum must always be read consistently.
+func TestConcurrentValidatorSetUpdates(t *testing.T) {
+	all := []Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes()}
+	w := New()
+	w.UpdateValidatorSet(all)
+
+	// quorums indexes the expected quorum by the number of validators.
+	quorums := map[int]int{4: 3, 2: 2, 1: 5}
+
+	fns := []func(){
+		func() {
+			for i := 0; i < 100; i++ {
+				w.UpdateValidatorSet(all[:2])
+				w.UpdateValidatorSetWeighted(map[ID]uint64{ID(pub0.String()): 6})
+				w.UpdateValidatorSet(all)
+			}
+		},
+		func() {
+			var prev *Vote
+			for i := 0; i < 100; i++ {
+				vote := NewVote(pub0, uint64(i), testTx0)
+				if prev != nil {
+					vote.WithPrevHash(prev.Hash())
+				}
+				prev = vote
+				_, err := w.AddVote(vote)
+				assert.NoError(t, err)
+				w.IsBlocked(testTx0)
+				w.IsBlockedBy(testTx0, testTx1)
+			}
+		},
+		func() {
+			for i := 0; i < 100; i++ {
+				stats := w.Stats()
+				assert.Equal(t, quorums[stats.NumValidators], stats.Quorum)
+				assert.GreaterOrEqual(t, w.HonestMajority(), 0)
+				w.HonestParties()
+				w.ValidatorCount()
+			}
+		},
+	}
+
+	runConcurrently(fns...)
+}
+
+func TestMaxPendingTxs(t *testing.T) {
+	w := New(WithMaxPendingTxs(2))
+	require.NoError(t, w.AddTxE(testTx0))
+	assert.ErrorIs(t, w.AddTxE(testTx0), ErrTxAlreadyAdded)
+	require.True(t, w.AddTxWithRound(testTx1, 0))
+
+	assert.ErrorIs(t, w.AddTxE(testTx2), ErrMaxPendingTxs)
+	assert.False(t, w.AddTx(testTx2))
+	assert.False(t, w.AddTxWithDeadline(testTx2, time.Now()))
+	assert.False(t, w.AddTxWithDeps(testTx2, nil))
+	assert.Empty(t, w.deadlines)
+
+	// committed txs release room.
+	w.CommitBlock(Block{Txs: []Tx{testTx0}})
+	assert.NoError(t, w.AddTxE(testTx2))
+
+	t.Run("Unlimited", func(t *testing.T) {
+		w := New()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, w.AddTxE(NewSimpleTx(fmt.Sprintf("tx%d", i), fmt.Sprintf("hash%d", i))))
+		}
+	})
+}
+
+func TestQuorumReachedIsDeterministic(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub3.Bytes(), pub1.Bytes(), pub0.Bytes(), pub2.Bytes()})
+	// non-validator peers are iterated as well.
+	_, err := w.AddVote(NewVote(newRandPubkey(), 0, testTx0))
+	require.NoError(t, err)
+
+	var ids []ID
+	w.quorumReached(func(p *Peer) bool {
+		ids = append(ids, ID(p.pub.String()))
+		return false
+	})
+	require.Len(t, ids, 5)
+	assert.True(t, sort.SliceIsSorted(ids, func(i, j int) bool { return ids[i] < ids[j] }))
+	assert.Equal(t, w.peerIDs, ids)
+}
+
+func TestMinValidators(t *testing.T) {
+	w := New(WithMinValidators(3))
+	assert.True(t, w.IsHalted(), "no validators yet")
+
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+	require.False(t, w.IsHalted())
+
+	w.AddTx(testTx0)
+	require.NoError(t, w.AddVotes(
+		NewVote(pub0, 0, testTx0),
+		NewVote(pub1, 0, testTx0),
+		NewVote(pub2, 0, testTx0),
+	))
+	require.False(t, w.IsBlocked(testTx0))
+
+	t.Run("Strict", func(t *testing.T) {
+		_, err := w.UpdateValidatorSetStrict([]Validator{pub0.Bytes(), pub1.Bytes()})
+		assert.ErrorIs(t, err, ErrTooFewValidators)
+		assert.False(t, w.IsHalted(), "the validator set is not updated")
+		assert.Equal(t, 3, w.ValidatorCount())
+	})
+
+	t.Run("Halted", func(t *testing.T) {
+		w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+		assert.True(t, w.IsHalted())
+		assert.True(t, w.IsBlocked(testTx0))
+
+		w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+		assert.False(t, w.IsHalted())
+
+		// pub2 was removed from the set along with its votes.
+		_, err := w.AddVote(NewVote(pub2, 0, testTx0))
+		require.NoError(t, err)
+		assert.False(t, w.IsBlocked(testTx0), "the set has recovered")
+	})
+}
+
+func TestAdmissionPolicy(t *testing.T) {
+	errTooLarge := errors.New("tx too large")
+	w := New(WithAdmissionPolicy(func(tx Tx) error {
+		if len(tx.Bytes()) > 4 {
+			return errTooLarge
+		}
+		return nil
+	}))
+
+	assert.True(t, w.AddTx(NewSimpleTx("tx", "hash-a")))
+	assert.False(t, w.AddTx(NewSimpleTx("large-tx", "hash-b")))
+	assert.ErrorIs(t, w.AddTxE(NewSimpleTx("large-tx", "hash-b")), errTooLarge)
+	assert.False(t, w.AddTxWithRound(NewSimpleTx("large-tx", "hash-b"), 1))
+	assert.Equal(t, 1, w.Stats().NumTxs)
+}
+
+func TestIsStalled(t *testing.T) {
+	w := New(WithStallTimeout(time.Hour))
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes(), pub2.Bytes()})
+	assert.False(t, w.IsStalled(), "no pending txs")
+
+	w.AddTx(testTx0)
+	require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0)))
+	require.True(t, w.IsBlocked(testTx0))
+	assert.False(t, w.IsStalled(), "the timeout hasn't expired")
+
+	w.pendingAt = w.pendingAt.Add(-2 * time.Hour)
+	assert.False(t, w.IsStalled(), "a vote was added within the timeout")
+
+	w.votedAt = w.votedAt.Add(-2 * time.Hour)
+	assert.True(t, w.IsStalled())
+
+	// once a tx is unblocked, Wendy is not stalled anymore.
+	require.NoError(t, w.AddVotes(NewVote(pub1, 0, testTx0), NewVote(pub2, 0, testTx0)))
+	w.votedAt = w.votedAt.Add(-2 * time.Hour)
+	assert.False(t, w.IsStalled())
+}
+
+func TestTxsByLabel(t *testing.T) {
+	w := New()
+	orders := []Tx{
+		NewSimpleTx("tx-a", "hash-a").withLabel("order"),
+		NewSimpleTx("tx-c", "hash-c").withLabel("order"),
+	}
+	w.AddTx(orders[0])
+	w.AddTx(NewSimpleTx("tx-b", "hash-b").withLabel("transfer"))
+	w.AddTx(orders[1])
+
+	assert.Equal(t, orders, w.TxsByLabel("order"))
+	assert.Empty(t, w.TxsByLabel("unknown"))
+
+	w.CommitBlock(Block{Txs: orders[:1]})
+	assert.Equal(t, orders[1:], w.TxsByLabel("order"))
+}
+
+func TestRangeTxs(t *testing.T) {
+	w := New()
+	allTxs := []Tx{testTx0, testTx1, testTx2}
+	for _, tx := range allTxs {
+		w.AddTx(tx)
+	}
+
+	var txs []Tx
+	w.RangeTxs(func(tx Tx) bool {
+		txs = append(txs, tx)
+		return true
+	})
+	assert.Equal(t, allTxs, txs)
+
+	t.Run("StopsEarly", func(t *testing.T) {
+		var txs []Tx
+		w.RangeTxs(func(tx Tx) bool {
+			txs = append(txs, tx)
+			return len(txs) < 2
+		})
+		assert.Equal(t, allTxs[:2], txs)
+	})
+}
+
+func TestCommitBlockPrunesState(t *testing.T) {
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: allTestTxs,
+			&pub1: allTestTxs,
+		},
+	)
+	peer := w.peers[ID(pub0.String())]
+
+	for _, test := range []struct {
+		committed []Tx
+		remaining int
+	}{
+		{committed: []Tx{testTx0, testTx1}, remaining: 4},
+		{committed: []Tx{testTx2}, remaining: 3},
+		{committed: []Tx{testTx3, testTx4, testTx5}, remaining: 0},
+	} {
+		w.CommitBlock(Block{Txs: test.committed})
+
+		for _, tx := range test.committed {
+			assert.Nil(t, w.VoteByTxHash(tx.Hash()))
+		}
+		assert.Len(t, w.txs.List(), test.remaining)
+		assert.Len(t, w.votes, test.remaining)
+		assert.Len(t, peer.Votes(), test.remaining)
+	}
+}
+
+func TestVoteSigning(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+
+	key := Pubkey(pub)
+	vote := NewVote(key, 0, testTx0)
+	sv := NewSignedVote(priv, vote)
+
+	require.True(t, sv.Verify())
+
+	sv.Data.Pubkey = pub0
 	require.False(t, sv.Verify(), "verify should fails when pubkey updated")
+
+	t.Run("PubkeyEqual", func(t *testing.T) {
+		assert.True(t, key.Equal(Pubkey(append([]byte(nil), pub...))))
+		assert.False(t, key.Equal(pub0))
+		assert.False(t, key.Equal(key[:16]), "different lengths")
+		assert.False(t, key.Equal(nil))
+		assert.True(t, Pubkey(nil).Equal(Pubkey{}))
+
+		// a key differing only on the last byte.
+		other := append(Pubkey(nil), key...)
+		other[len(other)-1] ^= 0xff
+		assert.False(t, key.Equal(other))
+
+		sv := NewSignedVote(priv, NewVote(other, 0, testTx0))
+		assert.False(t, sv.Verify(), "signed with a different key")
+		assert.ErrorIs(t, sv.VerifyE(), ErrBadSignature)
+	})
+
+	t.Run("VerifyE", func(t *testing.T) {
+		sv := NewSignedVote(priv, NewVote(key, 0, testTx0))
+		require.NoError(t, sv.VerifyE())
+		require.NoError(t, sv.VerifyTx(testTx0))
+
+		err := sv.VerifyTx(testTx1)
+		assert.ErrorIs(t, err, ErrTxHashMismatch)
+		assert.Contains(t, err.Error(), shortHash(testTx0.Hash()))
+
+		sv.Data.Pubkey = key[:16]
+		assert.ErrorIs(t, sv.VerifyE(), ErrPubkeyLen)
+		assert.ErrorIs(t, sv.VerifyE(), ErrInvalidSignature)
+
+		sv.Data.Pubkey = pub0
+		assert.ErrorIs(t, sv.VerifyE(), ErrBadSignature)
+		assert.ErrorIs(t, sv.VerifyTx(testTx0), ErrBadSignature)
+		assert.ErrorIs(t, sv.VerifyE(), ErrInvalidSignature)
+	})
+}
+
+func TestVerifyBatch(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(Rand)
+	require.NoError(t, err)
+
+	var votes []*SignedVote
+	for i, tx := range []Tx{testTx0, testTx1, testTx2, testTx3, testTx4} {
+		votes = append(votes, NewSignedVote(priv, NewVote(Pubkey(pub), uint64(i), tx)))
+	}
+	votes[1].Data.Pubkey = pub0
+	votes[3].Data.Pubkey = Pubkey(pub[:16])
+
+	errs := VerifyBatch(votes)
+	require.Len(t, errs, len(votes))
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], ErrBadSignature)
+	assert.NoError(t, errs[2])
+	assert.ErrorIs(t, errs[3], ErrPubkeyLen)
+	assert.NoError(t, errs[4])
+
+	assert.Empty(t, VerifyBatch(nil))
+}
+
+func TestGracefulTransition(t *testing.T) {
+	// vote0 is pub0's vote for testTx0.
+	var vote0 *Vote
+	setup := func(opts ...Option) *Wendy {
+		w := New(opts...)
+		w.UpdateValidatorSet([]Validator{
+			pub0.Bytes(), pub1.Bytes(), pub2.Bytes(), pub3.Bytes(),
+		})
+		w.AddTx(testTx0)
+		vote0 = NewVote(pub0, 0, testTx0)
+		require.NoError(t, w.AddVotes(
+			vote0, NewVote(pub1, 0, testTx0), NewVote(pub2, 0, testTx0),
+		))
+		require.False(t, w.IsBlocked(testTx0))
+
+		// pub1 and pub2 are rotated out, the new quorum is 2 out of 2.
+		w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub3.Bytes()})
+		return w
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		w := setup()
+		assert.True(t, w.IsBlocked(testTx0))
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		w := setup(WithGracefulTransition())
+		assert.False(t, w.IsBlocked(testTx0), "pending txs use the previous set")
+
+		// new txs use the new set.
+		w.AddTx(testTx1)
+		require.NoError(t, w.AddVotes(
+			NewVote(pub0, 1, testTx1).WithPrevHash(vote0.Hash()),
+			NewVote(pub3, 0, testTx1),
+		))
+		assert.False(t, w.IsBlocked(testTx1), "2 out of 4 is not a quorum on the previous set")
+
+		// the transition ends once its txs are committed.
+		w.CommitBlock(Block{Txs: []Tx{testTx0}})
+		assert.Nil(t, w.transition)
+	})
+
+	t.Run("NextUpdate", func(t *testing.T) {
+		w := setup(WithGracefulTransition())
+		w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub3.Bytes()})
+		assert.True(t, w.IsBlocked(testTx0), "the previous transition is discarded")
+	})
+}
+
+func TestAddTxWithDeps(t *testing.T) {
+	pubs := []Pubkey{pub0, pub1, pub2, pub3}
+	w := New()
+	var vs []Validator
+	for _, pub := range pubs {
+		vs = append(vs, pub.Bytes())
+	}
+	w.UpdateValidatorSet(vs)
+
+	// testTx0 must follow testTx2 even though every validator votes it first,
+	// testTx1 is voted last.
+	require.True(t, w.AddTxWithDeps(testTx0, []Hash{testTx2.Hash()}))
+	require.True(t, w.AddTx(testTx1))
+	require.True(t, w.AddTx(testTx2))
+	require.False(t, w.AddTxWithDeps(testTx2, nil))
+	for _, pub := range pubs {
+		var prev *Vote
+		for seq, tx := range []Tx{testTx0, testTx2, testTx1} {
+			vote := NewVote(pub, uint64(seq), tx)
+			if prev != nil {
+				vote.WithPrevHash(prev.Hash())
+			}
+			require.NoError(t, w.AddVotes(vote))
+			prev = vote
+		}
+	}
+
+	set := w.BlockingSet()
+	assert.Equal(t, []Tx{testTx0, testTx2}, set[testTx0.Hash()])
+	assert.Equal(t, []Tx{testTx0, testTx1, testTx2}, set[testTx1.Hash()])
+	assert.Equal(t, []Tx{testTx2}, set[testTx2.Hash()], "testTx2 is never blocked by testTx0")
+	assert.Equal(t, set, w.BlockingSetCached())
+	assert.True(t, w.IsBlockedBy(testTx2, testTx0), "IsBlockedBy only reflects the votes")
+
+	order, err := set.Order()
+	require.NoError(t, err)
+	assert.Equal(t, []Tx{testTx2, testTx0, testTx1}, order)
+	// blocks are sorted by hash, except for the declared dependencies.
+	assert.Equal(t, []Tx{testTx1, testTx2, testTx0}, w.NewBlock().Txs)
+	assert.Equal(t, []Tx{testTx2, testTx0}, w.NewBlockWithOptions(NewBlockOptions{TxLimit: 2}).Txs)
+
+	// once testTx2 is committed the dependency is satisfied.
+	w.CommitBlock(Block{Txs: []Tx{testTx2}})
+	assert.Equal(t, []Tx{testTx0}, w.BlockingSet()[testTx0.Hash()])
+
+	t.Run("FairnessLoop", func(t *testing.T) {
+		w := New()
+		w.UpdateValidatorSet(vs)
+
+		// testTx0 must follow testTx1, while the votes place testTx0 before
+		// testTx2 and testTx2 before testTx1, which closes a loop.
+		require.True(t, w.AddTxWithDeps(testTx0, []Hash{testTx1.Hash()}))
+		require.True(t, w.AddTx(testTx1))
+		require.True(t, w.AddTx(testTx2))
+		for _, pub := range pubs {
+			var prev *Vote
+			for seq, tx := range []Tx{testTx0, testTx2, testTx1} {
+				vote := NewVote(pub, uint64(seq), tx)
+				if prev != nil {
+					vote.WithPrevHash(prev.Hash())
+				}
+				require.NoError(t, w.AddVotes(vote))
+				prev = vote
+			}
+		}
+
+		set := w.BlockingSet()
+		require.Len(t, set.Cycles(), 1)
+
+		order, err := w.Order(set)
+		assert.ErrorIs(t, err, ErrFairnessLoop)
+		assert.Equal(t, []Tx{testTx1, testTx0, testTx2}, order)
+
+		order, _ = set.Order()
+		assert.Equal(t, []Tx{testTx0, testTx1, testTx2}, order, "the set alone sorts the loop by hash")
+		assert.Equal(t, []Tx{testTx1, testTx0, testTx2}, w.NewBlock().Txs)
+	})
+
+	t.Run("LabelFilter", func(t *testing.T) {
+		w := New()
+		w.UpdateValidatorSet(vs)
+
+		// txA depends on txB, which has a different label.
+		txA := NewSimpleTx("tx-a", "hash-a").withLabel("a")
+		txB := NewSimpleTx("tx-b", "hash-b").withLabel("b")
+		require.True(t, w.AddTxWithDeps(txA, []Hash{txB.Hash()}))
+		require.True(t, w.AddTx(txB))
+
+		onlyA := func(label string) bool { return label == "a" }
+		assert.Empty(t, w.NewBlockWithOptions(NewBlockOptions{LabelFilter: onlyA}).Txs,
+			"txA can't be selected without txB")
+
+		onlyB := func(label string) bool { return label == "b" }
+		assert.Equal(t, []Tx{txB}, w.NewBlockWithOptions(NewBlockOptions{LabelFilter: onlyB}).Txs)
+		assert.Equal(t, []Tx{txB, txA}, w.NewBlock().Txs)
+	})
+}
+
+func TestTopoSort(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		preds [][]int
+		order []int
+	}{
+		{"NoPreds", [][]int{nil, nil, nil}, []int{0, 1, 2}},
+		{"Chain", [][]int{{1}, {2}, nil}, []int{2, 1, 0}},
+		{"LowestReadyFirst", [][]int{{2}, nil, nil, {1}}, []int{1, 2, 0, 3}},
+		// 0 and 1 form a loop, the lowest index is placed first.
+		{"Loop", [][]int{{1}, {0}, {0}}, []int{0, 1, 2}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.order, topoSort(test.preds))
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	w := New()
+	w.UpdateValidatorSet([]Validator{pub0.Bytes(), pub1.Bytes()})
+	w.AddTx(testTx0)
+	w.AddTx(testTx1)
+
+	vote0 := NewVote(pub0, 0, testTx0)
+	require.NoError(t, w.AddVotes(vote0, NewVote(pub0, 1, testTx1).WithPrevHash(vote0.Hash())))
+	w.CommitBlock(Block{Txs: []Tx{testTx0}})
+
+	// pub1's vote arrives after testTx0 was committed, testTx2's vote arrives
+	// before the tx is added.
+	require.NoError(t, w.AddVotes(NewVote(pub1, 0, testTx0), NewVote(pub1, 2, testTx2)))
+	require.NotNil(t, w.VoteByTxHash(testTx0.Hash()))
+
+	assert.Equal(t, 1, w.Compact())
+	assert.Equal(t, 0, w.Compact())
+	assert.Nil(t, w.VoteByTxHash(testTx0.Hash()))
+	assert.NotNil(t, w.VoteByTxHash(testTx2.Hash()), "votes for txs not added yet are kept")
+	assert.True(t, w.peers[ID(pub0.String())].Voted(testTx1))
+}
+
+func TestPartitions(t *testing.T) {
+	var (
+		txA0 = NewSimpleTx("txA0", "hA0").withLabel("A")
+		txA1 = NewSimpleTx("txA1", "hA1").withLabel("A")
+		txB0 = NewSimpleTx("txB0", "hB0").withLabel("B")
+		txB1 = NewSimpleTx("txB1", "hB1").withLabel("B")
+	)
+
+	// every validator orders A's txs in the same way, but B's txs form a
+	// fairness loop, which must not affect A.
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: {txA0, txB0, txA1, txB1},
+			&pub1: {txB1, txA0, txB0, txA1},
+			&pub2: {txA0, txB1, txA1, txB0},
+			&pub3: {txB0, txA0, txA1, txB1},
+		},
+	)
+	require.Equal(t, 3, w.Quorum())
+
+	assert.False(t, w.IsBlockedBy(txA0, txA1))
+	assert.True(t, w.IsBlockedBy(txA1, txA0))
+	assert.False(t, w.IsBlockedBy(txA0, txB0), "partitions don't block each other")
+	assert.False(t, w.IsBlockedBy(txB0, txA1))
+
+	set := w.BlockingSet()
+	assert.Equal(t, []Tx{txA0}, set[txA0.Hash()])
+	assert.Equal(t, []Tx{txA0, txA1}, set[txA1.Hash()])
+	assert.Equal(t, [][]Hash{{txB0.Hash(), txB1.Hash()}}, set.Cycles())
+	for _, edge := range set.Edges() {
+		tx1, tx2 := w.txs.ByHash(edge[0]), w.txs.ByHash(edge[1])
+		assert.Equal(t, tx1.Label(), tx2.Label())
+	}
+}
+
+func TestReset(t *testing.T) {
+	w := newTestWendy(t,
+		map[*Pubkey][]Tx{
+			&pub0: {testTx0, testTx1},
+			&pub1: {testTx0, testTx1},
+		},
+	)
+	w.UpdateValidatorSetWeighted(map[ID]uint64{
+		ID(pub0.String()): 2,
+		ID(pub1.String()): 1,
+	})
+	_, err := w.AddVote(NewVote(pub2, 0, testTx0))
+	require.NoError(t, err)
+	require.False(t, w.IsBlocked(testTx0))
+
+	w.Reset()
+	assert.Equal(t, Stats{NumPeers: 2, Quorum: 3, NumValidators: 2}, w.Stats())
+	assert.Nil(t, w.VoteByTxHash(testTx0.Hash()))
+	assert.Equal(t, uint64(2), w.peers[ID(pub0.String())].Weight())
+
+	// txs and votes can be added again from scratch.
+	w.AddTx(testTx0)
+	require.NoError(t, w.AddVotes(NewVote(pub0, 0, testTx0), NewVote(pub1, 0, testTx0)))
+	assert.False(t, w.IsBlocked(testTx0))
 }